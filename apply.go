@@ -0,0 +1,149 @@
+package tageval
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"unsafe"
+)
+
+// DefaultTag gives the value a field is set to by Apply if it holds the
+// zero value, as in
+//
+//	Currency string   `json:"currency" default:"USD"`
+//	Retries  int      `json:"retries" default:"3"`
+//	Tags     []string `json:"tags" default:"[\"new\"]"`
+//
+// A string field takes the text as it is, a field whose type implements
+// encoding.TextUnmarshaler, such as time.Time, unmarshals it, and any
+// other field takes it as JSON.  A nil pointer is set to point to the
+// default.
+const DefaultTag = "default"
+
+// NormalizeTag gives a comma separated list of the normalizations Apply
+// makes to a string field, or the string a pointer field points to, in
+// order, as in
+//
+//	Email string `json:"email" normalize:"trim,lower"`
+//
+// The normalizations are "trim", removing leading and trailing white
+// space, "lower" and "upper", changing the case, and "collapse",
+// replacing each run of white space with a single space.
+const NormalizeTag = "normalize"
+
+var spaceRE = regexp.MustCompile(`\s+`)
+
+// normalizers are the normalizations of the normalize tag.
+var normalizers = map[string]func(string) string{
+	"trim":     strings.TrimSpace,
+	"lower":    strings.ToLower,
+	"upper":    strings.ToUpper,
+	"collapse": func(s string) string { return spaceRE.ReplaceAllString(s, " ") },
+}
+
+// Apply sets the fields of the item, which must be a non-nil pointer, to
+// their defaults and normalizes them, as their default and normalize tags
+// say, typically before the item is validated.  Unlike the rest of the
+// Validator, which never modifies data, Apply changes the item in place.
+//
+// The item is walked as Validate walks it, following the Validator's
+// Convention, and with it in effect, private fields and those left out
+// of serialization are left alone.  Without it, private fields are set
+// through the unsafe package, as for ValidateAddressable.  The defaults
+// are set before the normalizations are made, and before the walk enters
+// the field, so the fields of a struct a pointer is defaulted to get
+// their defaults too.  Values that can't be set, such as fields of struct
+// values held in maps or interfaces, are an error if tagged.  The item
+// may be partly changed when an error is returned.
+func (v Validator) Apply(itemPtr interface{}) error {
+	rv := reflect.ValueOf(itemPtr)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("supplied item (%v) is not a non-nil pointer",
+			itemPtr)
+	}
+	return v.traverse(rv.Elem(), &walk{apply: true}, "")
+}
+
+// applyTags sets a field to its default and normalizes it.
+func (v Validator) applyTags(f reflect.StructField, fi FieldInfo,
+	val reflect.Value, w *walk) error {
+	def, hasDef := f.Tag.Lookup(DefaultTag)
+	norm := f.Tag.Get(NormalizeTag)
+	if (!hasDef && norm == "") || fi.Skip {
+		return nil
+	}
+
+	if !val.CanSet() {
+		if w.safe || !val.CanAddr() {
+			return fmt.Errorf("cannot set field '%s'", f.Name)
+		}
+		val = reflect.NewAt(val.Type(), unsafe.Pointer(val.UnsafeAddr())).Elem()
+	}
+
+	if hasDef && val.IsZero() {
+		if err := setDefault(val, def); err != nil {
+			return fmt.Errorf("invalid %s tag for field '%s': %v",
+				DefaultTag, f.Name, err)
+		}
+	}
+	if norm != "" {
+		if err := normalize(val, norm); err != nil {
+			return fmt.Errorf("invalid %s tag for field '%s': %v",
+				NormalizeTag, f.Name, err)
+		}
+	}
+	return nil
+}
+
+// setDefault sets a settable value from the text of a default tag.
+func setDefault(val reflect.Value, def string) error {
+	switch {
+	case val.Kind() == reflect.String:
+		val.SetString(def)
+		return nil
+	case val.Kind() == reflect.Ptr:
+		pv := reflect.New(val.Type().Elem())
+		if err := setDefault(pv.Elem(), def); err != nil {
+			return err
+		}
+		val.Set(pv)
+		return nil
+	}
+	p := val.Addr().Interface()
+	if tu, ok := p.(encoding.TextUnmarshaler); ok {
+		return tu.UnmarshalText([]byte(def))
+	}
+	return json.Unmarshal([]byte(def), p)
+}
+
+// normalize makes the normalizations of a normalize tag to a settable
+// string value, or the string a pointer points to, if not nil.
+func normalize(val reflect.Value, norm string) error {
+	var funcs []func(string) string
+	for _, name := range strings.Split(norm, ",") {
+		f, ok := normalizers[strings.TrimSpace(name)]
+		if !ok {
+			return fmt.Errorf("unknown normalization %q", name)
+		}
+		funcs = append(funcs, f)
+	}
+
+	if val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return nil
+		}
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.String {
+		return fmt.Errorf("cannot normalize %v, not a string", val.Type())
+	}
+	s := val.String()
+	for _, f := range funcs {
+		s = f(s)
+	}
+	val.SetString(s)
+	return nil
+}
@@ -0,0 +1,75 @@
+package tageval
+
+import (
+	"testing"
+	"time"
+)
+
+type Signup struct {
+	Email    string    `json:"email" normalize:"trim,lower" regexp:"^[a-z]+@[a-z.]+$"`
+	Name     *string   `json:"name" normalize:"trim, collapse"`
+	Country  string    `json:"country" default:" us " normalize:"trim,upper"`
+	Retries  int       `json:"retries" default:"3"`
+	Rate     *float64  `json:"rate" default:"0.5"`
+	Tags     []string  `json:"tags" default:"[\"new\"]"`
+	Since    time.Time `json:"since" default:"2024-01-01T00:00:00Z"`
+	Profile  *Profile  `json:"profile" default:"{}"`
+	Extras   []Profile `json:"extras"`
+	Internal string    `json:"-" default:"x"`
+	private  string    `default:"hidden"`
+}
+
+type Profile struct {
+	Theme string `json:"theme" default:"dark"`
+}
+
+func TestApply(t *testing.T) {
+	name := "  Ann \t Lee "
+	s := Signup{Email: " Ann@Example.com ", Name: &name, Retries: 5,
+		Extras: []Profile{{}, {Theme: "light"}}}
+	v, _ := NewValidator()
+	if err := v.Apply(&s); err != nil {
+		t.Fatalf("apply failed with error: %v", err)
+	}
+	if s.Email != "ann@example.com" || *s.Name != "Ann Lee" ||
+		s.Country != "US" || s.Retries != 5 || *s.Rate != 0.5 ||
+		len(s.Tags) != 1 || s.Tags[0] != "new" ||
+		!s.Since.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) ||
+		s.Profile.Theme != "dark" || s.Extras[0].Theme != "dark" ||
+		s.Extras[1].Theme != "light" || s.Internal != "" || s.private != "" {
+		t.Fatalf("unexpected result %+v", s)
+	}
+	if ok, _, err := v.Validate(s); !ok || err != nil {
+		t.Fatalf("applied item did not validate: %v", err)
+	}
+
+	// Without serialization rules, private fields are set too.
+	v, _ = NewValidator(AsJSON(false))
+	var s2 Signup
+	if err := v.Apply(&s2); err != nil {
+		t.Fatalf("apply failed with error: %v", err)
+	}
+	if s2.private != "hidden" || s2.Internal != "x" {
+		t.Fatalf("unexpected result %+v", s2)
+	}
+
+	v, _ = NewValidator()
+	for _, item := range []interface{}{
+		s,
+		(*Signup)(nil),
+		&struct {
+			N int `default:"three"`
+		}{},
+		&struct {
+			N int `normalize:"trim"`
+		}{},
+		&struct {
+			S string `normalize:"trim,shout"`
+		}{},
+		&map[string]Profile{"a": {}},
+	} {
+		if err := v.Apply(item); err == nil {
+			t.Fatalf("did not get expected error for %#v", item)
+		}
+	}
+}
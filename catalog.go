@@ -0,0 +1,257 @@
+package tageval
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+
+	"golang.org/x/text/language"
+)
+
+// A Catalog holds the messages for failed Results in several languages,
+// for showing to the people whose data failed validation.  A message is
+// keyed by the Code of the Result, or by its kind, such as "required",
+// for a generic message for any rule of that kind.  It is a text/template
+// executed with the Result, so that it can include the name, path or
+// value, as in
+//
+//	{"Order.Total.expr": "{{.Name}} must be more than zero, not {{.Value}}"}
+//
+// A message is looked up in each of the languages asked for in turn, and
+// then in the fallback languages, trying each language's parents along
+// the way, so that "de-CH" falls back on "de".  Within a language, a
+// message for the Code is preferred to one for the kind.
+//
+// A Catalog may be shared by any number of Validators and goroutines.
+type Catalog struct {
+	mu       sync.RWMutex
+	fallback []language.Tag
+	messages map[language.Tag]map[string]*template.Template
+}
+
+// NewCatalog returns an empty Catalog, falling back on the given
+// languages for messages missing from those asked for.
+func NewCatalog(fallback ...language.Tag) *Catalog {
+	return &Catalog{
+		fallback: fallback,
+		messages: make(map[language.Tag]map[string]*template.Template),
+	}
+}
+
+// Add adds the message for the key in the language, replacing any it
+// already had.
+func (c *Catalog) Add(lang language.Tag, key, msg string) error {
+	tmpl, err := template.New(key).Parse(msg)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.messages[lang] == nil {
+		c.messages[lang] = make(map[string]*template.Template)
+	}
+	c.messages[lang][key] = tmpl
+	return nil
+}
+
+// Load adds the messages of the files in fsys matching the pattern, as
+// understood by fs.Glob, such as "locales/*.json".  Files are named for
+// their language, as in "de.json" or "pt-BR.po".
+//
+// A ".json" file holds an object mapping keys to messages.  A ".po" file
+// holds gettext entries, with the key as the msgid and the message as the
+// msgstr.  Its header's Language, if any, overrides the file name, and
+// entries marked fuzzy are skipped, as gettext skips them.  Plural forms
+// are not supported.
+func (c *Catalog) Load(fsys fs.FS, pattern string) error {
+	names, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("no message files match %s", pattern)
+	}
+	for _, name := range names {
+		if err := c.loadFile(fsys, name); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func (c *Catalog) loadFile(fsys fs.FS, name string) error {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return err
+	}
+	ext := path.Ext(name)
+	code := strings.TrimSuffix(path.Base(name), ext)
+
+	var msgs map[string]string
+	switch ext {
+	case ".json":
+		err = json.Unmarshal(data, &msgs)
+	case ".po":
+		var header string
+		msgs, header, err = parsePO(data)
+		if hc := poLanguage(header); hc != "" {
+			code = hc
+		}
+	default:
+		return fmt.Errorf("unknown message file type %s", ext)
+	}
+	if err != nil {
+		return err
+	}
+	lang, err := language.Parse(code)
+	if err != nil {
+		return err
+	}
+	for key, msg := range msgs {
+		if err := c.Add(lang, key, msg); err != nil {
+			return fmt.Errorf("message %s: %v", key, err)
+		}
+	}
+	return nil
+}
+
+// Languages returns the languages with messages in the catalog.
+func (c *Catalog) Languages() []language.Tag {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var langs []language.Tag
+	for lang := range c.messages {
+		langs = append(langs, lang)
+	}
+	sort.Slice(langs, func(i, j int) bool {
+		return langs[i].String() < langs[j].String()
+	})
+	return langs
+}
+
+// Message returns the message for the Result in the first of the
+// languages, or of the fallback languages, that has one, or "" if none
+// of them does.
+func (c *Catalog) Message(res Result, langs ...language.Tag) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var keys []string
+	if res.Code != "" {
+		keys = append(keys, res.Code)
+	}
+	keys = append(keys, string(res.Kind))
+	for _, lang := range append(langs[:len(langs):len(langs)], c.fallback...) {
+		for t := lang; ; t = t.Parent() {
+			for _, key := range keys {
+				tmpl, ok := c.messages[t][key]
+				if !ok {
+					continue
+				}
+				var b bytes.Buffer
+				if err := tmpl.Execute(&b, res); err == nil {
+					return b.String()
+				}
+			}
+			if t == language.Und {
+				break
+			}
+		}
+	}
+	return ""
+}
+
+// parsePO parses the entries of a gettext PO file, returning the header,
+// the msgstr of the empty msgid, apart.
+func parsePO(data []byte) (map[string]string, string, error) {
+	msgs := make(map[string]string)
+	var header string
+	var id, str *string
+	var msgid, msgstr string
+	var fuzzy, seen bool
+	flush := func() {
+		switch {
+		case !seen:
+		case msgid == "":
+			header = msgstr
+		case !fuzzy && msgstr != "":
+			msgs[msgid] = msgstr
+		}
+		msgid, msgstr, fuzzy, seen = "", "", false, false
+		id, str = nil, nil
+	}
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case line == "":
+			flush()
+		case strings.HasPrefix(line, "#,"):
+			if str != nil {
+				flush()
+			}
+			fuzzy = fuzzy || strings.Contains(line, "fuzzy")
+		case strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "msgid_plural"),
+			strings.HasPrefix(line, "msgstr["):
+			return nil, "", fmt.Errorf("line %d: plural forms are not supported", n)
+		case strings.HasPrefix(line, "msgctxt"):
+			return nil, "", fmt.Errorf("line %d: contexts are not supported", n)
+		case strings.HasPrefix(line, "msgid "):
+			if str != nil {
+				flush()
+			}
+			s, err := strconv.Unquote(strings.TrimSpace(line[len("msgid "):]))
+			if err != nil {
+				return nil, "", fmt.Errorf("line %d: %v", n, err)
+			}
+			msgid, id, str, seen = s, &msgid, nil, true
+		case strings.HasPrefix(line, "msgstr "):
+			if id == nil {
+				return nil, "", fmt.Errorf("line %d: msgstr without msgid", n)
+			}
+			s, err := strconv.Unquote(strings.TrimSpace(line[len("msgstr "):]))
+			if err != nil {
+				return nil, "", fmt.Errorf("line %d: %v", n, err)
+			}
+			msgstr, id, str = s, nil, &msgstr
+		case strings.HasPrefix(line, `"`):
+			// A continuation of the msgid or msgstr before it.
+			s, err := strconv.Unquote(line)
+			if err != nil {
+				return nil, "", fmt.Errorf("line %d: %v", n, err)
+			}
+			switch {
+			case id != nil:
+				*id += s
+			case str != nil:
+				*str += s
+			default:
+				return nil, "", fmt.Errorf("line %d: unexpected string", n)
+			}
+		default:
+			return nil, "", fmt.Errorf("line %d: unexpected %q", n, line)
+		}
+	}
+	flush()
+	return msgs, header, sc.Err()
+}
+
+// poLanguage returns the Language given in a PO file header.
+func poLanguage(header string) string {
+	for _, line := range strings.Split(header, "\n") {
+		if k, v, ok := strings.Cut(line, ":"); ok &&
+			strings.TrimSpace(k) == "Language" {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
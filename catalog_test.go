@@ -0,0 +1,130 @@
+package tageval
+
+import (
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"golang.org/x/text/language"
+)
+
+var locales = fstest.MapFS{
+	"locales/en.json": {Data: []byte(`{
+		"Parcel.Weight.expr": "{{.Name}} must be under 30kg, not {{.Value}}kg",
+		"Parcel.Code.regexp": "{{.Name}} must be two letters",
+		"required": "{{.Name}} is required"
+	}`)},
+	"locales/de.json": {Data: []byte(`{
+		"Parcel.Weight.expr": "{{.Name}} muss unter 30kg liegen, nicht {{.Value}}kg",
+		"regexp": "{{.Name}} hat das falsche Format"
+	}`)},
+	"locales/messages.po": {Data: []byte(`# Portuguese messages
+msgid ""
+msgstr ""
+"Language: pt-BR\n"
+"Content-Type: text/plain; charset=UTF-8\n"
+
+msgid "Parcel.Weight.expr"
+msgstr "{{.Name}} deve ser inferior a "
+"30kg"
+
+#, fuzzy
+msgid "required"
+msgstr "{{.Name}} talvez"
+`)},
+}
+
+type Parcel struct {
+	Weight int    `json:"weight" expr:"< 30"`
+	Code   string `json:"code" regexp:"^[A-Z]{2}$"`
+	From   string `json:"from" regexp:"^[A-Z]{2}$"`
+	To     string `json:"to" required:"true"`
+	PIN    string `json:"pin" sensitive:"true" expr:"PIN.length == 4" code:"pin"`
+}
+
+func TestCatalog(t *testing.T) {
+	c := NewCatalog(language.English)
+	if err := c.Load(locales, "locales/*"); err != nil {
+		t.Fatalf("loading failed with error: %v", err)
+	}
+	if err := c.Add(language.English, "pin", "{{.Name}} {{.Value}} is wrong"); err != nil {
+		t.Fatalf("adding failed with error: %v", err)
+	}
+	var langs []string
+	for _, lang := range c.Languages() {
+		langs = append(langs, lang.String())
+	}
+	if len(langs) != 3 || langs[0] != "de" || langs[1] != "en" ||
+		langs[2] != "pt-BR" {
+		t.Fatalf("unexpected languages %v", langs)
+	}
+
+	v, _ := NewValidator(WithCatalog(c), ShowSuccesses(true))
+	item := Parcel{Weight: 31, Code: "x", From: "DE", PIN: "123"}
+	for _, test := range []struct {
+		langs    []language.Tag
+		expected []string
+	}{
+		{nil, []string{"Weight must be under 30kg, not 31kg",
+			"Code must be two letters", "To is required",
+			"PIN [REDACTED] is wrong"}},
+		// The German message for any regexp beats the English one
+		// for this regexp.
+		{[]language.Tag{language.MustParse("de-CH")}, []string{
+			"Weight muss unter 30kg liegen, nicht 31kg",
+			"Code hat das falsche Format", "To is required",
+			"PIN [REDACTED] is wrong"}},
+		// The fuzzy message is skipped.
+		{[]language.Tag{language.French, language.BrazilianPortuguese},
+			[]string{"Weight deve ser inferior a 30kg",
+				"Code must be two letters", "To is required",
+				"PIN [REDACTED] is wrong"}},
+	} {
+		_, res, err := v.Localized(test.langs...).Validate(item)
+		if err != nil {
+			t.Fatalf("validation failed with error: %v", err)
+		}
+		PrintResults(os.Stdout, res)
+		var got []string
+		for _, r := range res {
+			if r.Valid && r.Message != "" {
+				t.Fatalf("unexpected message for valid result: %s", r.Message)
+			}
+			if !r.Valid {
+				got = append(got, r.Message)
+			}
+		}
+		if len(got) != len(test.expected) {
+			t.Fatalf("expected messages %q, got %q", test.expected, got)
+		}
+		for i := range got {
+			if got[i] != test.expected[i] {
+				t.Fatalf("expected messages %q, got %q", test.expected, got)
+			}
+		}
+	}
+
+	// Without a fallback, there may be no message at all.
+	if msg := NewCatalog().Message(Result{Kind: ExprRule}); msg != "" {
+		t.Fatalf("unexpected message %q", msg)
+	}
+}
+
+func TestCatalogErrors(t *testing.T) {
+	for name, data := range map[string]string{
+		"xx-bad!.json": `{}`,
+		"de.json":      `{"a": "{{.Name"}`,
+		"de.po":        "msgid \"a\"\nmsgid_plural \"as\"\n",
+		"fr.po":        "msgstr \"a\"\n",
+		"it.po":        "msgid \"a\nmsgstr \"b\"\n",
+		"es.yaml":      "a: b\n",
+	} {
+		c := NewCatalog()
+		if err := c.Load(fstest.MapFS{name: {Data: []byte(data)}}, "*"); err == nil {
+			t.Fatalf("did not get expected error for %s", name)
+		}
+	}
+	if err := NewCatalog().Load(locales, "none/*.json"); err == nil {
+		t.Fatalf("did not get expected error for no files")
+	}
+}
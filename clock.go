@@ -0,0 +1,90 @@
+package tageval
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robertkrimen/otto"
+)
+
+// WithClock sets the clock that expressions tell the time by, in place of
+// time.Now, so that rules such as `expr:"ExpiresAt > now"` can be tested
+// at a fixed time.  It is used by Date.now(), by new Date() and Date()
+// without arguments, and by the now variable.
+func WithClock(clock func() time.Time) Option {
+	return func(v *Validator) {
+		v.clock = clock
+	}
+}
+
+// WithRandom sets the source of Math.random() in expressions, which must
+// return numbers in [0, 1), as rand.Float64 does, so that rules using it
+// behave the same way from one run to the next.
+func WithRandom(random func() float64) Option {
+	return func(v *Validator) {
+		v.random = random
+	}
+}
+
+// environScript returns a function that installs the clock and random
+// source, each if given, and defines now as the current time.  Date is
+// replaced by a function that builds the real thing, so that instanceof
+// and the prototype are unchanged.
+const environScript = `(function(global) {
+	return function(clock, random) {
+		if (clock) {
+			var RealDate = Date;
+			var FakeDate = function Date(a0, a1, a2, a3, a4, a5, a6) {
+				if (!(this instanceof FakeDate)) {
+					return new RealDate(clock()).toString();
+				}
+				switch (arguments.length) {
+				case 0: return new RealDate(clock());
+				case 1: return new RealDate(a0);
+				case 2: return new RealDate(a0, a1);
+				case 3: return new RealDate(a0, a1, a2);
+				case 4: return new RealDate(a0, a1, a2, a3);
+				case 5: return new RealDate(a0, a1, a2, a3, a4);
+				case 6: return new RealDate(a0, a1, a2, a3, a4, a5);
+				default: return new RealDate(a0, a1, a2, a3, a4, a5, a6);
+				}
+			};
+			FakeDate.prototype = RealDate.prototype;
+			FakeDate.prototype.constructor = FakeDate;
+			FakeDate.now = function() { return clock(); };
+			FakeDate.parse = RealDate.parse;
+			FakeDate.UTC = RealDate.UTC;
+			global.Date = FakeDate;
+		}
+		if (random) {
+			Math.random = function() { return random(); };
+		}
+		Object.defineProperty(global, "now", {
+			get: function() { return new Date(); },
+			configurable: true
+		});
+	};
+})(this)`
+
+// environ sets up the engine's clock and random source.
+func (e *evaluator) environ(clock func() time.Time,
+	random func() float64) error {
+	install, err := e.vm.Run(environScript)
+	if err != nil {
+		return fmt.Errorf("setting up clock: %v", err)
+	}
+	var jsClock, jsRandom interface{}
+	if clock != nil {
+		jsClock = func() int64 {
+			return clock().UnixMilli()
+		}
+	}
+	if random != nil {
+		jsRandom = random
+	}
+	_, err = install.Call(otto.UndefinedValue(), jsClock, jsRandom)
+	if err != nil {
+		return fmt.Errorf("setting up clock: %v", err)
+	}
+	return nil
+}
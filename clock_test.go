@@ -0,0 +1,65 @@
+package tageval
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+type Coupon struct {
+	ExpiresAt time.Time `expr:"ExpiresAt > now"`
+	IssuedAt  time.Time `expr:"Date.now() - IssuedAt.getTime() < 24 * 60 * 60 * 1000"`
+	Year      int       `expr:"new Date().getFullYear() == Year && Date().indexOf(String(Year)) >= 0"`
+	Launch    int       `expr:"var d = new Date(2020, 0, Launch); d instanceof Date && d.constructor === Date && d.getDate() == Launch"`
+	Draw      float64   `expr:"Math.random() == Draw"`
+}
+
+func TestClock(t *testing.T) {
+	fixed := time.Date(2020, time.June, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return fixed }
+	random := func() float64 { return 0.25 }
+	c := Coupon{
+		ExpiresAt: fixed.Add(time.Minute),
+		IssuedAt:  fixed.Add(-time.Hour),
+		Year:      2020,
+		Launch:    3,
+		Draw:      0.25,
+	}
+	for _, opts := range [][]Option{
+		{WithClock(clock), WithRandom(random)},
+		{Sandbox(SandboxConfig{}), WithClock(clock), WithRandom(random)},
+	} {
+		v, err := NewValidator(append(opts, ShowSuccesses(true))...)
+		if err != nil {
+			t.Fatalf("creating validator failed with error: %v", err)
+		}
+		ok, res, err := v.Copy().Validate(c)
+		if err != nil {
+			t.Fatalf("validation failed with error: %v", err)
+		}
+		PrintResults(os.Stdout, res)
+		if !ok {
+			t.Fatalf("unexpected failure with fixed clock")
+		}
+		correlate(t, res, []checker{
+			{"ExpiresAt", true},
+			{"IssuedAt", true},
+			{"Year", true},
+			{"Launch", true},
+			{"Draw", true},
+		})
+	}
+
+	// Without a clock, now is the time.
+	v, _ := NewValidator()
+	ok, res, err := v.Validate(Coupon{ExpiresAt: time.Now().Add(time.Hour),
+		IssuedAt: time.Now(), Year: time.Now().Year(), Launch: 3, Draw: -1})
+	if err != nil {
+		t.Fatalf("validation failed with error: %v", err)
+	}
+	PrintResults(os.Stdout, res)
+	if ok {
+		t.Fatalf("unexpected success with random draw")
+	}
+	correlate(t, res, []checker{{"Draw", false}})
+}
@@ -0,0 +1,46 @@
+// Command tagevalcheckgen writes the main package of a command that
+// validates JSON and NDJSON files against types from another package,
+// using the filecheck package:
+//
+//	tagevalcheckgen [-o main.go] github.com/acme/orders Order Line
+//
+// Build the result as usual, and run it with -help for its flags.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/gdotgordon/tageval/filecheck"
+)
+
+var output = flag.String("o", "main.go", `output file name, or "-" for stdout`)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr,
+			"usage: tagevalcheckgen [-o file] package type...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var b bytes.Buffer
+	if err := filecheck.GenerateMain(&b, flag.Arg(0), flag.Args()[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "tagevalcheckgen: %v\n", err)
+		os.Exit(1)
+	}
+	if *output == "-" {
+		os.Stdout.Write(b.Bytes())
+		return
+	}
+	if err := os.WriteFile(*output, b.Bytes(), 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "tagevalcheckgen: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,99 @@
+// Command tagevalgen writes reflection-free validation methods for
+// structs with tageval tags, for use with go generate:
+//
+//	//go:generate tagevalgen -type Order,Line
+//
+// With no -type flag, every struct type in the package with a rule that
+// can be generated gets a method.  The output goes to
+// <type>_tageval.go, after the first type named, or tageval_gen.go, in
+// the package directory, unless set with -output.  If the Validator is
+// given other tag names, the -expr, -regexp and -tag flags name them.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gdotgordon/tageval"
+	"github.com/gdotgordon/tageval/tagevalgen"
+	"golang.org/x/tools/go/packages"
+)
+
+var (
+	typeNames = flag.String("type", "",
+		"comma-separated list of struct type names")
+	output  = flag.String("output", "", "output file name")
+	exprTag = flag.String("expr", tageval.ExprTag,
+		"name of the struct tag holding JavaScript expressions")
+	regexpTag = flag.String("regexp", tageval.RegexpTag,
+		"name of the struct tag holding regular expressions")
+	convTag = flag.String("tag", "json",
+		"name of the struct tag giving serialized names, as json does")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: tagevalgen [-type T,...] [-output file] "+
+		"[-expr tag] [-regexp tag] [-tag tag] [package]\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	patterns := flag.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+	if len(patterns) > 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	cfg := &packages.Config{Mode: tagevalgen.LoadMode}
+	pkgs, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		fatal(err)
+	}
+	if len(pkgs) != 1 {
+		fatal(fmt.Errorf("%d packages found", len(pkgs)))
+	}
+	pkg := pkgs[0]
+	if len(pkg.Errors) > 0 {
+		fatal(pkg.Errors[0])
+	}
+
+	var names []string
+	if *typeNames != "" {
+		names = strings.Split(*typeNames, ",")
+	}
+	src, err := tagevalgen.GenerateWith(pkg, names, tagevalgen.Options{
+		ExprTag:       *exprTag,
+		RegexpTag:     *regexpTag,
+		ConventionTag: *convTag,
+	})
+	if err != nil {
+		fatal(err)
+	}
+
+	name := *output
+	if name == "" {
+		name = "tageval_gen.go"
+		if len(names) > 0 {
+			name = strings.ToLower(names[0]) + "_tageval.go"
+		}
+		if len(pkg.GoFiles) > 0 {
+			name = filepath.Join(filepath.Dir(pkg.GoFiles[0]), name)
+		}
+	}
+	if err := os.WriteFile(name, src, 0o644); err != nil {
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "tagevalgen: %v\n", err)
+	os.Exit(1)
+}
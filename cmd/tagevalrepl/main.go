@@ -0,0 +1,209 @@
+// Command tagevalrepl evaluates JavaScript expressions against a sample
+// JSON document, using the same engine, conversions and type mappings as
+// expr tags:
+//
+//	tagevalrepl [-name doc] [-times] [-fields=false] file.json
+//
+// The document is bound to the variable doc (or the -name given), and
+// unless -fields=false, each top-level property that is a valid
+// identifier is also bound as its own variable, just as a struct field is
+// bound to its name for its expr tag.  With -times, strings holding
+// RFC 3339 timestamps are decoded as time.Time, so that the time.Time to
+// Date mapping can be tried out.
+//
+// Each line read is evaluated and its result shown with its JavaScript
+// type.  The command ":types [name]" shows the JavaScript type of every
+// value reachable from a bound variable, with the Go type it came from
+// and whether a type mapping was applied, and ":quit" exits.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gdotgordon/tageval"
+)
+
+var identRE = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+const help = `Enter a JavaScript expression to evaluate it, or one of:
+  :types [name]  show the JavaScript types of a variable and its contents
+  :vars          list the bound variables
+  :help          show this message
+  :quit          exit
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("tagevalrepl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	name := fs.String("name", "doc", "variable name for the document")
+	times := fs.Bool("times", false,
+		"decode RFC 3339 timestamp strings as time.Time")
+	fields := fs.Bool("fields", true,
+		"bind each top-level property as a variable too")
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: tagevalrepl [flags] file.json\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "tagevalrepl: %v\n", err)
+		return 1
+	}
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		fmt.Fprintf(stderr, "tagevalrepl: %s: %v\n", fs.Arg(0), err)
+		return 1
+	}
+	if *times {
+		doc = parseTimes(doc)
+	}
+
+	v, err := tageval.NewValidator(tageval.WithConsole(
+		func(msg tageval.ConsoleMessage) {
+			fmt.Fprintln(stdout, msg.Text)
+		}))
+	if err != nil {
+		fmt.Fprintf(stderr, "tagevalrepl: %v\n", err)
+		return 1
+	}
+	vars := map[string]interface{}{*name: doc}
+	if obj, ok := doc.(map[string]interface{}); ok && *fields {
+		for k, val := range obj {
+			if k == *name || !identRE.MatchString(k) {
+				continue
+			}
+			// Leave the JavaScript built-ins alone.
+			if jv, err := v.Eval("typeof " + k); err != nil ||
+				jv.Text != `"undefined"` {
+				continue
+			}
+			vars[k] = val
+		}
+	}
+	for k, val := range vars {
+		if err := v.Bind(k, val); err != nil {
+			fmt.Fprintf(stderr, "tagevalrepl: binding %s: %v\n", k, err)
+			return 1
+		}
+	}
+	fmt.Fprintf(stdout, "Bound %s.  Type :help for help.\n",
+		strings.Join(sortedNames(vars), ", "))
+
+	in := bufio.NewScanner(stdin)
+	for {
+		fmt.Fprint(stdout, "> ")
+		if !in.Scan() {
+			fmt.Fprintln(stdout)
+			break
+		}
+		line := strings.TrimSpace(in.Text())
+		cmd, arg, _ := strings.Cut(line, " ")
+		switch cmd {
+		case "":
+		case ":quit", ":q":
+			return 0
+		case ":help":
+			fmt.Fprint(stdout, help)
+		case ":vars":
+			for _, k := range sortedNames(vars) {
+				fmt.Fprintln(stdout, k)
+			}
+		case ":types":
+			target := strings.TrimSpace(arg)
+			if target == "" {
+				target = *name
+			}
+			val, ok := vars[target]
+			if !ok {
+				fmt.Fprintf(stdout, "no variable %s\n", target)
+				continue
+			}
+			res, err := v.Inspect(target, val)
+			if err != nil {
+				fmt.Fprintf(stdout, "error: %v\n", err)
+				continue
+			}
+			printTypes(stdout, res)
+		default:
+			jv, err := v.Eval(line)
+			if err != nil {
+				fmt.Fprintf(stdout, "error: %v\n", err)
+				continue
+			}
+			fmt.Fprintf(stdout, "%s (%s)\n", jv.Text, jv.Type)
+		}
+	}
+	if err := in.Err(); err != nil {
+		fmt.Fprintf(stderr, "tagevalrepl: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// printTypes lists each value with its JavaScript and Go types.
+func printTypes(w io.Writer, res []tageval.JSValue) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, jv := range res {
+		goType := "-"
+		if jv.GoType != nil {
+			goType = jv.GoType.String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s", jv.Expr, jv.Type, goType)
+		if jv.Mapped {
+			fmt.Fprintf(tw, "\t(mapped %s -> %s)", goType, jv.Type)
+		}
+		fmt.Fprintln(tw)
+	}
+	tw.Flush()
+}
+
+// parseTimes replaces the RFC 3339 timestamp strings in a decoded
+// document with time.Time values.
+func parseTimes(doc interface{}) interface{} {
+	switch d := doc.(type) {
+	case string:
+		if t, err := time.Parse(time.RFC3339Nano, d); err == nil {
+			return t
+		}
+	case map[string]interface{}:
+		for k, v := range d {
+			d[k] = parseTimes(v)
+		}
+	case []interface{}:
+		for i, v := range d {
+			d[i] = parseTimes(v)
+		}
+	}
+	return doc
+}
+
+func sortedNames(vars map[string]interface{}) []string {
+	var names []string
+	for k := range vars {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
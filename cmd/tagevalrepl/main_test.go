@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	in := strings.NewReader(`placed.getUTCFullYear()
+doc.lines[0].qty * 2
+console.log('hello from', 'js')
+id.length > 3 &&
+:types placed
+:types
+:vars
+:quit
+`)
+	var out, errs bytes.Buffer
+	code := run([]string{"-times", "testdata/order.json"}, in, &out, &errs)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, errs.String())
+	}
+	for _, expected := range []string{
+		"Bound doc, id, lines, note, placed.",
+		"2024 (number)",
+		"4 (number)",
+		"hello from js\nundefined (undefined)",
+		"error: ",
+		"placed  Date  time.Time  (mapped time.Time -> Date)",
+		"doc.placed        Object     time.Time\n",
+		`doc["gift wrap"]  boolean    bool`,
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Fatalf("expected output to contain %q", expected)
+		}
+	}
+
+	if code := run(nil, in, &out, &errs); code != 2 {
+		t.Fatalf("expected exit code 2, got %d", code)
+	}
+}
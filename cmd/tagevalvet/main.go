@@ -0,0 +1,18 @@
+// Command tagevalvet checks the expr and regexp struct tags used by
+// tageval.  It may be run on packages directly:
+//
+//	tagevalvet ./...
+//
+// or by go vet:
+//
+//	go vet -vettool=$(which tagevalvet) ./...
+package main
+
+import (
+	"github.com/gdotgordon/tageval/tagevalvet"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(tagevalvet.Analyzer)
+}
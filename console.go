@@ -0,0 +1,86 @@
+package tageval
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/robertkrimen/otto"
+)
+
+// A ConsoleMessage is the output of a call to console.log or one of its
+// siblings in an expression.
+type ConsoleMessage struct {
+	// Level is the name of the console method called: "log", "debug",
+	// "info", "warn" or "error".
+	Level string
+
+	// Text is the arguments of the call, each converted to a string and
+	// joined by spaces, as the console shows them.
+	Text string
+
+	// Field is the name of the field whose expression made the call, and
+	// Expr the expression.  For Validator.Eval, Field is empty and Expr
+	// is the source evaluated.
+	Field string
+	Expr  string
+}
+
+// A ConsoleFunc receives the console output of expressions.
+type ConsoleFunc func(msg ConsoleMessage)
+
+// consoleLevels are the console methods passed on to a ConsoleFunc.
+var consoleLevels = []string{"log", "debug", "info", "warn", "error"}
+
+// WithConsole passes the console output of expressions to a function,
+// rather than writing it to standard output, where it would corrupt the
+// output of a command or be lost in a service.  With the Sandbox option,
+// console is made available again for the function's sake.
+func WithConsole(f ConsoleFunc) Option {
+	return func(v *Validator) {
+		v.eval.console = f
+	}
+}
+
+// WithConsoleLogger logs the console output of expressions with the
+// slog.Logger, at the level matching the console method, with the field
+// and expression as the "field" and "expr" attributes.
+func WithConsoleLogger(l *slog.Logger) Option {
+	return WithConsole(func(msg ConsoleMessage) {
+		level := slog.LevelInfo
+		switch msg.Level {
+		case "debug":
+			level = slog.LevelDebug
+		case "warn":
+			level = slog.LevelWarn
+		case "error":
+			level = slog.LevelError
+		}
+		l.Log(context.Background(), level, msg.Text,
+			slog.String("field", msg.Field), slog.String("expr", msg.Expr))
+	})
+}
+
+// installConsole replaces the engine's console with one that calls the
+// ConsoleFunc.
+func (e *evaluator) installConsole(vm *otto.Otto) error {
+	console, err := vm.Object(`({})`)
+	if err != nil {
+		return err
+	}
+	for _, level := range consoleLevels {
+		err := console.Set(level, func(call otto.FunctionCall) otto.Value {
+			args := make([]string, len(call.ArgumentList))
+			for i, arg := range call.ArgumentList {
+				args[i] = arg.String()
+			}
+			e.console(ConsoleMessage{Level: level,
+				Text: strings.Join(args, " "), Field: e.field, Expr: e.expr})
+			return otto.UndefinedValue()
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return vm.Set("console", console)
+}
@@ -0,0 +1,72 @@
+package tageval
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+type Debugged struct {
+	W int    `expr:"console.log('W is', W); console.warn('checking', typeof W); W > 0"`
+	S string `expr:"console.error(S.length, [1, 2]); S != ''"`
+}
+
+func TestConsole(t *testing.T) {
+	var msgs []ConsoleMessage
+	hook := WithConsole(func(msg ConsoleMessage) {
+		msgs = append(msgs, msg)
+	})
+	wExpr := "console.log('W is', W); console.warn('checking', typeof W); W > 0"
+	sExpr := "console.error(S.length, [1, 2]); S != ''"
+	expected := []ConsoleMessage{
+		{"log", "W is 3", "W", wExpr},
+		{"warn", "checking number", "W", wExpr},
+		{"error", "2 1,2", "S", sExpr},
+	}
+	for _, opts := range [][]Option{
+		{hook},
+		{Sandbox(SandboxConfig{}), hook},
+	} {
+		v, err := NewValidator(opts...)
+		if err != nil {
+			t.Fatalf("creating validator failed with error: %v", err)
+		}
+		for _, cv := range []*Validator{v, v.Copy()} {
+			msgs = nil
+			ok, _, err := cv.Validate(Debugged{W: 3, S: "ab"})
+			if err != nil || !ok {
+				t.Fatalf("unexpected validation result: %t, %v", ok, err)
+			}
+			if len(msgs) != len(expected) {
+				t.Fatalf("expected messages %+v, got %+v", expected, msgs)
+			}
+			for i := range msgs {
+				if msgs[i] != expected[i] {
+					t.Fatalf("expected messages %+v, got %+v", expected, msgs)
+				}
+			}
+		}
+	}
+
+	var b bytes.Buffer
+	v, _ := NewValidator(WithConsoleLogger(slog.New(slog.NewTextHandler(&b,
+		&slog.HandlerOptions{
+			ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+				if a.Key == slog.TimeKey {
+					return slog.Attr{}
+				}
+				return a
+			},
+		}))))
+	v.Validate(Debugged{W: 3, S: "ab"})
+	for _, want := range []string{
+		`level=INFO msg="W is 3" field=W expr=`,
+		`level=WARN msg="checking number" field=W`,
+		`level=ERROR msg="2 1,2" field=S expr="console.error(S.length, [1, 2]); S != ''"`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Fatalf("log does not contain %s:\n%s", want, b.String())
+		}
+	}
+}
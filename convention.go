@@ -0,0 +1,106 @@
+package tageval
+
+import (
+	"reflect"
+	"strings"
+)
+
+// A Convention describes the serialization rules a Validator obeys
+// when deciding whether a struct field takes part in validation, and
+// what the field is called once serialized.  The JSON rules are the
+// default, but any encoding that follows the familiar
+// `tag:"name,omitempty"` and `tag:"-"` layout, such as XML, YAML, BSON
+// or form encoding, may be plugged in via the WithConvention option.
+type Convention interface {
+	// Field reports how the convention treats the struct field.
+	Field(f reflect.StructField) FieldInfo
+}
+
+// FieldInfo is what a Convention has to say about a single struct field.
+type FieldInfo struct {
+	// Name is the serialized name of the field.
+	Name string
+
+	// Skip is set if the field is never serialized, i.e. `json:"-"`.
+	Skip bool
+
+	// OmitEmpty is set if the field is left out when it holds the
+	// zero value for its type.
+	OmitEmpty bool
+
+	// Inline is set if the fields of a struct-valued field are
+	// serialized as though they belonged to the enclosing struct,
+	// as is the case for embedded structs in JSON.
+	Inline bool
+
+	// Quoted is set if the field has the "string" option, with which
+	// encoding/json stores a scalar value within a JSON string.
+	Quoted bool
+}
+
+// The built-in conventions for the common Go serialization tags.
+var (
+	JSONConvention = TagConvention("json")
+	XMLConvention  = TagConvention("xml")
+	YAMLConvention = TagConvention("yaml")
+	BSONConvention = TagConvention("bson")
+	FormConvention = TagConvention("form")
+)
+
+// TagConvention returns a Convention driven by the named struct tag.
+// The first comma-separated element of the tag is the field name (the
+// Go field name is used if it is empty), a tag of exactly "-" skips
+// the field, and an "omitempty" element among the remaining options
+// omits the field when it is the zero value.  An embedded struct with
+// no name in the tag, or a field with the "inline" option, is inlined,
+// and a "string" option sets Quoted.
+func TagConvention(tag string) Convention {
+	return tagConvention(tag)
+}
+
+type tagConvention string
+
+func (tc tagConvention) Field(f reflect.StructField) FieldInfo {
+	fi := FieldInfo{Name: f.Name}
+	tag, ok := f.Tag.Lookup(string(tc))
+	if !ok {
+		fi.Inline = isEmbeddedStruct(f)
+		return fi
+	}
+	if tag == "-" {
+		fi.Skip = true
+		return fi
+	}
+	name, opts, _ := strings.Cut(tag, ",")
+	if name != "" {
+		fi.Name = name
+	} else {
+		fi.Inline = isEmbeddedStruct(f)
+	}
+	for opts != "" {
+		var opt string
+		opt, opts, _ = strings.Cut(opts, ",")
+		switch opt {
+		case "omitempty":
+			fi.OmitEmpty = true
+		case "inline":
+			fi.Inline = true
+		case "string":
+			fi.Quoted = true
+		}
+	}
+	return fi
+}
+
+// isEmbeddedStruct reports whether the field is an embedded struct
+// or pointer to struct.
+func isEmbeddedStruct(f reflect.StructField) bool {
+	if !f.Anonymous {
+		return false
+	}
+	t := f.Type
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct
+}
@@ -0,0 +1,63 @@
+package tageval
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestTagConvention(t *testing.T) {
+	type Tagged struct {
+		A int `xml:"a,attr,omitempty"`
+		B int `xml:"-"`
+		C int `xml:",omitempty"`
+		D int `xml:"-,"`
+		E int
+	}
+
+	tt := reflect.TypeOf(Tagged{})
+	expected := []FieldInfo{
+		{Name: "a", OmitEmpty: true},
+		{Name: "B", Skip: true},
+		{Name: "C", OmitEmpty: true},
+		{Name: "-"},
+		{Name: "E"},
+	}
+	for i, fi := range expected {
+		got := XMLConvention.Field(tt.Field(i))
+		if got != fi {
+			t.Fatalf("field %d: expected %+v, got %+v", i, fi, got)
+		}
+	}
+}
+
+func TestXMLConvention(t *testing.T) {
+	type Order struct {
+		ID    string `xml:"id,attr" regexp:"^[0-9]+$"`
+		Total int    `xml:"total,omitempty" json:"total" expr:"> 5"`
+		Note  string `xml:"-" json:"note" expr:"Note.length > 0"`
+	}
+
+	o := Order{ID: "12a"}
+
+	// Under XML rules, Total is omitted and Note is skipped.
+	v, _ := NewValidator(WithConvention(XMLConvention), ShowSuccesses(true))
+	ok, res, err := v.Validate(o)
+	if err != nil {
+		t.Fatalf("validation failed with error: %v", err)
+	}
+	if ok {
+		t.Fatalf("unexpected success result")
+	}
+	PrintResults(os.Stdout, res)
+	correlate(t, res, []checker{{"ID", false}})
+
+	// Under the default JSON rules, both are validated.
+	v, _ = NewValidator(ShowSuccesses(true))
+	_, res, err = v.Validate(o)
+	if err != nil {
+		t.Fatalf("validation failed with error: %v", err)
+	}
+	correlate(t, res, []checker{{"ID", false}, {"Total", false},
+		{"Note", false}})
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"reflect"
 	"regexp"
+	"time"
 
 	"github.com/robertkrimen/otto"
 )
@@ -18,44 +19,69 @@ import (
 type evaluator struct {
 	vm      *otto.Otto
 	regexps map[string]*regexp.Regexp
-	mapping map[reflect.Type]internalTypeMapper
+	mapping map[reflect.Type]TypeMapper
 	scripts map[string]*otto.Script
-}
 
-// The internalTypeMapper takes an instance of a function of the public
-// type TypeMapper, and does the final step of creating an otto.Object
-// that represents the JavaScript instantiation code in the input function.
-type internalTypeMapper func(interface{}) (*otto.Object, error)
+	// With the Sandbox option, base is the locked down engine that vm is
+	// copied from afresh for each expression, and timeout caps the time
+	// each may run.
+	base    *otto.Otto
+	timeout time.Duration
+
+	// console receives the console output of expressions, if set, along
+	// with the field and expression being evaluated.
+	console ConsoleFunc
+	field   string
+	expr    string
+}
 
 func newEvaluator() *evaluator {
 	return &evaluator{
 		vm:      otto.New(),
 		regexps: make(map[string]*regexp.Regexp),
-		mapping: make(map[reflect.Type]internalTypeMapper),
+		mapping: make(map[reflect.Type]TypeMapper),
 		scripts: make(map[string]*otto.Script),
 	}
 }
 
 // addTypeMapping takes the user-defined conversion function,
-// which should return a js type-creation expression, and wraps
-// that in an otto object, so it may be used with the engine.
+// which should return a js type-creation expression.  The expression
+// is run by mapValue in whichever engine the value is bound in, so a
+// copy of the evaluator never touches the engine of the original.
 func (e *evaluator) addTypeMapping(t reflect.Type, f TypeMapper) {
-	e.mapping[t] = func(i interface{}) (*otto.Object, error) {
-		obj, err := e.vm.Object(f(i))
-		if err != nil {
-			return nil, fmt.Errorf(
-				"custom object creation error for %v: %s",
-				reflect.TypeOf(i), err)
-		}
-		return obj, nil
+	e.mapping[t] = f
+}
+
+// mapValue does the final step of a custom mapping, creating the
+// otto.Object the JavaScript instantiation code of the mapper yields.
+func (e *evaluator) mapValue(f TypeMapper, i interface{}) (*otto.Object,
+	error) {
+	obj, err := e.vm.Object(f(i))
+	if err != nil {
+		return nil, fmt.Errorf("custom object creation error for %v: %s",
+			reflect.TypeOf(i), err)
 	}
+	return obj, nil
 }
 
 func (e *evaluator) copy() *evaluator {
 	ce := &evaluator{}
 	ce.vm = e.vm.Copy()
+	if e.base != nil {
+		ce.base = e.base.Copy()
+	}
+	ce.timeout = e.timeout
+
+	// The console of the copy must report the copy's field and expression.
+	ce.console = e.console
+	if ce.console != nil {
+		ce.installConsole(ce.vm)
+		if ce.base != nil {
+			ce.installConsole(ce.base)
+		}
+	}
 	ce.regexps = make(map[string]*regexp.Regexp)
-	ce.mapping = make(map[reflect.Type]internalTypeMapper)
+	ce.mapping = make(map[reflect.Type]TypeMapper)
 	for k, v := range e.mapping {
 		ce.mapping[k] = v
 	}
@@ -68,41 +94,23 @@ func (e *evaluator) copy() *evaluator {
 // went wrong evaluatng the expression.
 func (e *evaluator) evalBoolExpr(name string, val interface{}, expr string) (
 	bool, error) {
+	e.reset()
+	return e.evalBound(name, val, expr)
+}
 
-	// First check if the type has a custom mapping function, and if so,
-	// use that.
-	f, ok := e.mapping[reflect.TypeOf(val)]
-	if ok {
-		var err error
-		val, err = f(val)
-		if err != nil {
-			return false, err
-		}
-	}
-
-	// Set the name of the variable (i.e. the field name) to
-	// its value, which is either it's current Go value, or
-	// the corresponding custom js type.
-	err := e.vm.Set(name, val)
-	if err != nil {
+// evalBound evaluates a boolean expression as evalBoolExpr does, in the
+// engine as it is, for callers that have bound other variables first.
+func (e *evaluator) evalBound(name string, val interface{}, expr string) (
+	bool, error) {
+	e.field, e.expr = name, expr
+	if _, err := e.bind(name, val); err != nil {
 		return false, err
 	}
 
-	// Memoize the expression into a Sript object if it's not
-	// already there.
-	script := e.scripts[expr]
-	if script == nil {
-		script, err = e.vm.Compile("", expr)
-		if err != nil {
-			return false, err
-		}
-		e.scripts[expr] = script
-	}
-
 	// Run the thing and get the boolean result (or capature any error).
 	// Note, an error should not happen under normal circumstances, as it
 	// is distinct from a validation function evaluating to "false".
-	res, err := e.vm.Run(script)
+	res, err := e.run(expr)
 	if err != nil {
 		return false, err
 	}
@@ -114,6 +122,54 @@ func (e *evaluator) evalBoolExpr(name string, val interface{}, expr string) (
 	return b, nil
 }
 
+// Set the name of the variable (i.e. the field name) to
+// its value, which is either it's current Go value, or
+// the corresponding custom js type.  Reports whether a custom
+// mapping was used.
+func (e *evaluator) bind(name string, val interface{}) (bool, error) {
+	// First check if the type has a custom mapping function, and if so,
+	// use that.
+	f, mapped := e.mapping[reflect.TypeOf(val)]
+	if mapped {
+		var err error
+		val, err = e.mapValue(f, val)
+		if err != nil {
+			return false, err
+		}
+	}
+	return mapped, e.vm.Set(name, val)
+}
+
+// define binds a variable as bind does, but for the sandbox, binds it in
+// the base, so that it stays set for every expression.
+func (e *evaluator) define(name string, val interface{}) (bool, error) {
+	if e.base == nil {
+		return e.bind(name, val)
+	}
+	e.vm = e.base
+	mapped, err := e.bind(name, val)
+	e.vm = e.base.Copy()
+	return mapped, err
+}
+
+// Run a script, memoizing it into a Script object if it's not
+// already there.
+func (e *evaluator) run(src string) (otto.Value, error) {
+	var err error
+	script := e.scripts[src]
+	if script == nil {
+		script, err = e.vm.Compile("", src)
+		if err != nil {
+			return otto.Value{}, err
+		}
+		e.scripts[src] = script
+	}
+	if e.timeout > 0 {
+		return e.runLimited(script)
+	}
+	return e.vm.Run(script)
+}
+
 // Evaluate a regular expression using the built-in Go mechanism.
 // The compiled expression is memoized for efficiency.
 func (e *evaluator) evalRegexp(val string, pattern string) (bool, error) {
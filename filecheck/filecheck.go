@@ -0,0 +1,258 @@
+// Package filecheck validates files of JSON or NDJSON records against a
+// type registered with tageval.RegisterType, for use in data pipelines
+// without writing Go.  A program registers its types and hands over to
+// Main:
+//
+//	func init() {
+//		tageval.RegisterType("Order", orders.Order{})
+//	}
+//
+//	func main() {
+//		os.Exit(filecheck.Main(os.Args[1:], os.Stdout, os.Stderr))
+//	}
+//
+// The tagevalcheckgen command writes such a program.
+//
+// A JSON file may hold a single record, an array of records, or a stream
+// of records one after another.  An NDJSON file, one named *.ndjson or
+// *.jsonl or any file with the -ndjson flag, holds one record per line.
+// Each record is decoded and validated with Validator.ValidateJSON, and
+// reported by one of the tageval Reporters as a ResultSet whose source is
+// the file and line the record starts on, as in "orders.ndjson:3".
+// Rules may be added to those in the tags, or override them, with rule
+// files named by the -rules flag, as read by Validator.LoadRules.
+package filecheck
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/gdotgordon/tageval"
+)
+
+// The exit codes returned by Main.
+const (
+	ExitOK      = 0
+	ExitInvalid = 1
+	ExitError   = 2
+)
+
+// Main runs the command line tool with the given arguments, writing the
+// report to stdout, and a summary and any errors to stderr, and returns
+// the exit code: ExitInvalid if any record failed, ExitError for bad
+// usage or unreadable files.
+func Main(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("filecheck", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	typeName := fs.String("type", "",
+		"registered type of the records (optional with only one type)")
+	format := fs.String("format", "text",
+		"report format: text, json, ndjson, csv, junit or tap")
+	ndjson := fs.Bool("ndjson", false,
+		"read every file as NDJSON, whatever its name")
+	rules := fs.String("rules", "",
+		"glob of rule files to add to or override the tags")
+	list := fs.Bool("list", false, "list the registered types")
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: %s [flags] file...\n", fs.Name())
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return ExitError
+	}
+
+	if *list {
+		for _, name := range tageval.RegisteredTypes() {
+			fmt.Fprintln(stdout, name)
+		}
+		return ExitOK
+	}
+	reporter, ok := tageval.LookupReporter(*format)
+	if !ok {
+		fmt.Fprintf(stderr, "unknown format %q\n", *format)
+		return ExitError
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return ExitError
+	}
+	t, err := recordType(*typeName)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return ExitError
+	}
+
+	// Keep console output from expressions out of the report.
+	v, err := tageval.NewValidator(tageval.WithConsoleLogger(
+		slog.New(slog.NewTextHandler(stderr, nil))))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return ExitError
+	}
+	if *rules != "" {
+		err := v.LoadRules(os.DirFS(filepath.Dir(*rules)),
+			filepath.Base(*rules))
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return ExitError
+		}
+	}
+	var sets []tageval.ResultSet
+	for _, name := range fs.Args() {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return ExitError
+		}
+		fr, err := CheckFile(*v, t, name, data, *ndjson || isNDJSON(name))
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return ExitError
+		}
+		sets = append(sets, fr...)
+	}
+
+	if err := reporter.Report(stdout, sets...); err != nil {
+		fmt.Fprintln(stderr, err)
+		return ExitError
+	}
+	failed := 0
+	for _, rs := range sets {
+		if !rs.Valid() {
+			failed++
+		}
+	}
+	fmt.Fprintf(stderr, "%d %s records checked, %d failed\n", len(sets),
+		t.Name(), failed)
+	if failed > 0 {
+		return ExitInvalid
+	}
+	return ExitOK
+}
+
+// recordType finds the registered type by name, which may be left empty
+// if only one type is registered.
+func recordType(name string) (reflect.Type, error) {
+	names := tageval.RegisteredTypes()
+	if name == "" {
+		if len(names) != 1 {
+			return nil, fmt.Errorf("-type is required, one of: %s",
+				strings.Join(names, ", "))
+		}
+		name = names[0]
+	}
+	t, ok := tageval.RegisteredType(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown type %q, registered types are: %s",
+			name, strings.Join(names, ", "))
+	}
+	return t, nil
+}
+
+func isNDJSON(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".ndjson", ".jsonl":
+		return true
+	}
+	return false
+}
+
+// CheckFile validates each record in the data of the named file as a
+// value of type t, returning the outcome of each.  A record that is not
+// well-formed JSON has an Err but no Results.  The error return is
+// reserved for a file whose records can't be told apart.
+func CheckFile(v tageval.Validator, t reflect.Type, name string,
+	data []byte, ndjson bool) ([]tageval.ResultSet, error) {
+	var sets []tageval.ResultSet
+	check := func(raw []byte, line int) {
+		rs := tageval.ResultSet{Source: fmt.Sprintf("%s:%d", name, line)}
+		target := reflect.New(t).Interface()
+		_, rs.Results, rs.Err = v.ValidateJSON(raw, target)
+		sets = append(sets, rs)
+	}
+
+	if ndjson {
+		for i, line := range bytes.Split(data, []byte("\n")) {
+			if len(bytes.TrimSpace(line)) > 0 {
+				check(line, i+1)
+			}
+		}
+		return sets, nil
+	}
+
+	lines := lineStarts(data)
+	dec := json.NewDecoder(bytes.NewReader(data))
+	start := skipSpace(data, 0)
+	if start < len(data) && data[start] == '[' {
+		if _, err := dec.Token(); err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		for dec.More() {
+			start := skipSpace(data, int(dec.InputOffset()))
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", name, lineOf(lines, start),
+					err)
+			}
+			check(raw, lineOf(lines, start))
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		start = skipSpace(data, int(dec.InputOffset()))
+		if start < len(data) {
+			return nil, fmt.Errorf("%s:%d: unexpected data after array", name,
+				lineOf(lines, start))
+		}
+		return sets, nil
+	}
+
+	for {
+		start := skipSpace(data, int(dec.InputOffset()))
+		var raw json.RawMessage
+		err := dec.Decode(&raw)
+		if errors.Is(err, io.EOF) {
+			return sets, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", name, lineOf(lines, start),
+				err)
+		}
+		check(raw, lineOf(lines, start))
+	}
+}
+
+// skipSpace returns the offset of the next value in a JSON array or
+// stream, skipping white space and a separating comma.
+func skipSpace(data []byte, i int) int {
+	for i < len(data) && strings.IndexByte(" \t\r\n,", data[i]) >= 0 {
+		i++
+	}
+	return i
+}
+
+// lineStarts returns the offset of the start of each line.
+func lineStarts(data []byte) []int {
+	starts := []int{0}
+	for i, c := range data {
+		if c == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return starts
+}
+
+// lineOf returns the 1-based line number holding the offset.
+func lineOf(starts []int, offset int) int {
+	return sort.SearchInts(starts, offset+1)
+}
@@ -0,0 +1,141 @@
+package filecheck
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/gdotgordon/tageval"
+)
+
+type Order struct {
+	ID  string `json:"id" regexp:"^[0-9]+$"`
+	Qty int    `json:"qty" required:"true" expr:"> 0"`
+}
+
+func init() {
+	tageval.RegisterType("Order", Order{})
+}
+
+func TestCheck(t *testing.T) {
+	var out, errs bytes.Buffer
+	code := Main([]string{"testdata/orders.json", "testdata/stream.json"},
+		&out, &errs)
+	if code != ExitInvalid {
+		t.Fatalf("expected exit code %d, got %d: %s", ExitInvalid, code,
+			errs.String())
+	}
+	expected := `Results for testdata/orders.json:3:
+'Qty' (type: int) item: '-1', expr: 'Qty > 0' : failed
+`
+	if out.String() != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+	if errs.String() != "5 Order records checked, 1 failed\n" {
+		t.Fatalf("unexpected summary: %s", errs.String())
+	}
+
+	out.Reset()
+	errs.Reset()
+	code = Main([]string{"-rules", "testdata/*.rules",
+		"testdata/orders.json"}, &out, &errs)
+	if code != ExitInvalid || !strings.Contains(out.String(),
+		"'ID' (type: string) item: '2', expr: '^[0-9]{3}$' : failed") {
+		t.Fatalf("rules not applied: %d, %s%s", code, out.String(),
+			errs.String())
+	}
+
+	out.Reset()
+	code = Main([]string{"-type", "Order", "testdata/stream.json"}, &out,
+		&errs)
+	if code != ExitOK {
+		t.Fatalf("expected exit code %d, got %d: %s", ExitOK, code,
+			errs.String())
+	}
+
+	for _, args := range [][]string{
+		nil,
+		{"-type", "Missing", "testdata/stream.json"},
+		{"-format", "yaml", "testdata/stream.json"},
+		{"testdata/missing.json"},
+		{"-rules", "testdata/none/*.rules", "testdata/stream.json"},
+	} {
+		if code := Main(args, &out, &errs); code != ExitError {
+			t.Fatalf("%v: expected exit code %d, got %d", args, ExitError,
+				code)
+		}
+	}
+}
+
+func TestNDJSON(t *testing.T) {
+	var out, errs bytes.Buffer
+	code := Main([]string{"-format", "json", "testdata/orders.ndjson"}, &out,
+		&errs)
+	if code != ExitInvalid {
+		t.Fatalf("expected exit code %d, got %d: %s", ExitInvalid, code,
+			errs.String())
+	}
+	var rep []struct {
+		Source  string
+		Valid   bool
+		Error   string
+		Results []struct{ Path string }
+	}
+	if err := json.Unmarshal(out.Bytes(), &rep); err != nil {
+		t.Fatalf("bad JSON report: %v", err)
+	}
+	if len(rep) != 4 || !rep[0].Valid || rep[1].Valid || rep[2].Valid ||
+		rep[3].Error == "" {
+		t.Fatalf("unexpected report: %s", out.String())
+	}
+	for i, expected := range []struct {
+		source string
+		paths  []string
+	}{
+		{"testdata/orders.ndjson:1", nil},
+		{"testdata/orders.ndjson:3", []string{"/id", "/qty"}},
+		{"testdata/orders.ndjson:4", []string{"/extra"}},
+		{"testdata/orders.ndjson:5", nil},
+	} {
+		if rep[i].Source != expected.source ||
+			len(rep[i].Results) != len(expected.paths) {
+			t.Fatalf("record %d: unexpected %+v", i, rep[i])
+		}
+		for j, p := range expected.paths {
+			if rep[i].Results[j].Path != p {
+				t.Fatalf("record %d: unexpected %+v", i, rep[i])
+			}
+		}
+	}
+
+	out.Reset()
+	Main([]string{"-format", "tap", "testdata/orders.ndjson",
+		"testdata/stream.json"}, &out, &errs)
+	if !strings.HasPrefix(out.String(), "TAP version 13\n1..7\n") ||
+		!strings.Contains(out.String(),
+			"not ok 3 - testdata/orders.ndjson:3 /qty expr\n") {
+		t.Fatalf("unexpected report: %s", out.String())
+	}
+}
+
+func TestGenerateMain(t *testing.T) {
+	var b bytes.Buffer
+	if err := GenerateMain(&b, "example.com/go-orders/v2",
+		[]string{"Order", "Line"}); err != nil {
+		t.Fatalf("generation failed with error: %v", err)
+	}
+	for _, expected := range []string{
+		`go_orders "example.com/go-orders/v2"`,
+		`tageval.RegisterType("Line", go_orders.Line{})`,
+		`os.Exit(filecheck.Main(os.Args[1:], os.Stdout, os.Stderr))`,
+	} {
+		if !strings.Contains(b.String(), expected) {
+			t.Fatalf("expected %q in:\n%s", expected, b.String())
+		}
+	}
+	if err := GenerateMain(&b, "example.com/orders",
+		[]string{"order"}); err == nil {
+		t.Fatalf("expected error for unexported type")
+	}
+}
@@ -0,0 +1,69 @@
+package filecheck
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io"
+	"path"
+	"strings"
+)
+
+// GenerateMain writes the source of a main package for a command that
+// registers the named types of the package with the given import path,
+// under their own names, and runs Main.
+func GenerateMain(w io.Writer, pkgPath string, typeNames []string) error {
+	if pkgPath == "" || len(typeNames) == 0 {
+		return fmt.Errorf("a package and at least one type are required")
+	}
+	for _, name := range typeNames {
+		if !token.IsIdentifier(name) || !token.IsExported(name) {
+			return fmt.Errorf("%q is not an exported type name", name)
+		}
+	}
+
+	// Name the import after the last path element, less any major
+	// version suffix, making it a valid identifier.
+	base := path.Base(pkgPath)
+	if strings.HasPrefix(base, "v") && len(base) > 1 &&
+		strings.Trim(base[1:], "0123456789") == "" {
+		base = path.Base(path.Dir(pkgPath))
+	}
+	alias := strings.Map(func(r rune) rune {
+		if r == '-' || r == '.' {
+			return '_'
+		}
+		return r
+	}, base)
+	switch alias {
+	case "main", "os", "tageval", "filecheck":
+		alias = "pkg" + alias
+	}
+	if !token.IsIdentifier(alias) {
+		alias = "pkg"
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by tagevalcheckgen; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "// The command validates JSON and NDJSON files of %s records.\n",
+		strings.Join(typeNames, ", "))
+	fmt.Fprintf(&b, "package main\n\nimport (\n\t\"os\"\n\n")
+	fmt.Fprintf(&b, "\t\"github.com/gdotgordon/tageval\"\n")
+	fmt.Fprintf(&b, "\t\"github.com/gdotgordon/tageval/filecheck\"\n")
+	fmt.Fprintf(&b, "\t%s %q\n)\n\n", alias, pkgPath)
+	fmt.Fprintf(&b, "func init() {\n")
+	for _, name := range typeNames {
+		fmt.Fprintf(&b, "\ttageval.RegisterType(%q, %s.%s{})\n", name, alias,
+			name)
+	}
+	fmt.Fprintf(&b, "}\n\nfunc main() {\n")
+	fmt.Fprintf(&b, "\tos.Exit(filecheck.Main(os.Args[1:], os.Stdout, os.Stderr))\n}\n")
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(src)
+	return err
+}
@@ -0,0 +1,67 @@
+package tageval
+
+import (
+	"reflect"
+	"unicode/utf8"
+)
+
+// A GeneratedValidator is a struct type with a validation method written
+// by the tagevalgen command.  The method checks the rules on the struct's
+// own fields in plain Go, returning a Result for each rule it evaluated,
+// whether valid or not, with the Type left unset.  Rules it cannot
+// express in Go, such as expressions calling JavaScript functions, are
+// left out.
+//
+// The Validator prefers the generated method to reflection and the
+// JavaScript engine whenever the pointer to a struct type implements
+// this interface.  A generated result is used only if its field, kind and
+// rule text all match what the Validator would have evaluated, and any
+// other rule is evaluated as usual, so generated code that has fallen out
+// of date with the tags is harmless, only slower.
+type GeneratedValidator interface {
+	TagevalValidate() []Result
+}
+
+var generatedType = reflect.TypeOf((*GeneratedValidator)(nil)).Elem()
+
+// generatedResults runs the generated validation method of a struct
+// value, if it has one.
+func generatedResults(val reflect.Value) []Result {
+	if !val.CanInterface() ||
+		!reflect.PointerTo(val.Type()).Implements(generatedType) {
+		return nil
+	}
+	p := val
+	if val.CanAddr() {
+		p = val.Addr()
+	} else {
+		p = reflect.New(val.Type())
+		p.Elem().Set(val)
+	}
+	return p.Interface().(GeneratedValidator).TagevalValidate()
+}
+
+// findGenerated looks for the generated result of a rule.
+func findGenerated(gen []Result, name string, kind RuleKind,
+	expr string) (Result, bool) {
+	for _, r := range gen {
+		if r.Name == name && r.Kind == kind && r.Expr == expr {
+			return r, true
+		}
+	}
+	return Result{}, false
+}
+
+// JSLength returns the length of a string as JavaScript reports it, in
+// UTF-16 code units.  It is used by generated code for "length".
+func JSLength(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= 0x10000 && r <= utf8.MaxRune {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,65 @@
+package tageval
+
+import (
+	"os"
+	"testing"
+)
+
+// Generated has a hand-written method in the style of tagevalgen, which
+// deliberately gets the regexp wrong, so the tests can tell it was used.
+type Generated struct {
+	Code  string `json:"code" regexp:"^[A-Z]+$"`
+	Total int    `json:"total" expr:"> 5"`
+	Name  string `json:"name" expr:"Name.trim().length > 0"`
+}
+
+func (x *Generated) TagevalValidate() []Result {
+	var res []Result
+	{
+		v := x.Code
+		res = append(res, Result{Name: "Code", Path: "/code", Value: v,
+			Kind: RegexpRule, Expr: `^[A-Z]+$`, Valid: v != "ABC"})
+	}
+	{
+		// Out of date with the tag, so must be ignored.
+		v := x.Total
+		res = append(res, Result{Name: "Total", Path: "/total", Value: v,
+			Kind: ExprRule, Expr: `Total > 4`, Valid: v > 4})
+	}
+	return res
+}
+
+func TestGenerated(t *testing.T) {
+	type Outer struct {
+		G  Generated   `json:"g"`
+		GS []Generated `json:"gs"`
+	}
+	gen := Generated{Code: "ABC", Total: 5, Name: " "}
+	o := Outer{G: gen, GS: []Generated{gen}}
+
+	v, _ := NewValidator()
+	for _, item := range []interface{}{o, &o} {
+		ok, res, err := v.Validate(item)
+		if err != nil {
+			t.Fatalf("validation failed with error: %v", err)
+		}
+		if ok {
+			t.Fatalf("unexpected success result")
+		}
+		PrintResults(os.Stdout, res)
+		correlate(t, res, []checker{{"Code", false}, {"Total", false},
+			{"Name", false}, {"Code", false}, {"Total", false},
+			{"Name", false}})
+		if res[3].Path != "/gs/0/code" || res[3].Type == nil {
+			t.Fatalf("unexpected result: %+v", res[3])
+		}
+	}
+}
+
+func TestJSLength(t *testing.T) {
+	for s, n := range map[string]int{"": 0, "abc": 3, "né": 2, "a😀": 3} {
+		if JSLength(s) != n {
+			t.Fatalf("%q: expected %d, got %d", s, n, JSLength(s))
+		}
+	}
+}
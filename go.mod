@@ -2,9 +2,14 @@ module github.com/gdotgordon/tageval
 
 go 1.23
 
-require github.com/robertkrimen/otto v0.5.1
+require (
+	github.com/robertkrimen/otto v0.5.1
+	golang.org/x/text v0.4.0
+	golang.org/x/tools v0.30.0
+)
 
 require (
-	golang.org/x/text v0.4.0 // indirect
+	golang.org/x/mod v0.23.0 // indirect
+	golang.org/x/sync v0.11.0 // indirect
 	gopkg.in/sourcemap.v1 v1.0.5 // indirect
 )
@@ -0,0 +1,243 @@
+// Package httpval decodes and validates JSON request bodies with tageval,
+// and reports failures as RFC 7807 problem details.
+//
+// A handler may either call Decode directly:
+//
+//	func create(w http.ResponseWriter, r *http.Request) {
+//		order, err := httpval.Decode[Order](r)
+//		if err != nil {
+//			httpval.WriteError(w, err)
+//			return
+//		}
+//		...
+//	}
+//
+// or be wrapped by Middleware, which writes the error response itself and
+// hands the decoded value to the handler via the request context:
+//
+//	http.Handle("/orders", httpval.Middleware[Order](http.HandlerFunc(create)))
+//	...
+//	order, _ := httpval.FromContext[Order](r.Context())
+package httpval
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/gdotgordon/tageval"
+	"golang.org/x/text/language"
+)
+
+// ProblemContentType is the media type of an RFC 7807 response.
+const ProblemContentType = "application/problem+json"
+
+// A Problem is the RFC 7807 problem details object written for a request
+// that could not be decoded or validated.  Errors lists each failed Result.
+type Problem struct {
+	Type   string         `json:"type"`
+	Title  string         `json:"title"`
+	Status int            `json:"status"`
+	Detail string         `json:"detail,omitempty"`
+	Errors []ProblemError `json:"errors,omitempty"`
+}
+
+// A ProblemError describes one failed Result.  The value itself is not
+// included, as it is echoed from the request and may be sensitive.  The
+// Severity tells the client which failures were only warnings, and the
+// Code which rule failed, for mapping to a message.  The Message is the
+// localized one from the Validator's Catalog, if it has one.
+type ProblemError struct {
+	Name     string `json:"name,omitempty"`
+	Pointer  string `json:"pointer"`
+	Rule     string `json:"rule"`
+	Expr     string `json:"expr"`
+	Severity string `json:"severity,omitempty"`
+	Code     string `json:"code,omitempty"`
+	Message  string `json:"message,omitempty"`
+}
+
+// A ValidationError is returned by Decode when the body was decoded, but
+// failed validation.
+type ValidationError struct {
+	Results []tageval.Result
+}
+
+func (e *ValidationError) Error() string {
+	var failed int
+	for _, r := range e.Results {
+		if r.Fails() {
+			failed++
+		}
+	}
+	return fmt.Sprintf("validation failed: %d failed result(s)", failed)
+}
+
+// A BodyError is returned by Decode when the body could not be read, or
+// is not well-formed JSON.
+type BodyError struct {
+	Err error
+}
+
+func (e *BodyError) Error() string {
+	return "invalid request body: " + e.Err.Error()
+}
+
+func (e *BodyError) Unwrap() error {
+	return e.Err
+}
+
+// MaxBodyBytes is the largest request body Decode reads.  A larger one
+// yields a *BodyError holding an *http.MaxBytesError.
+const MaxBodyBytes = 1 << 20
+
+// Validators may not be used concurrently, so Decode draws copies of one
+// from a pool rather than sharing it.
+var pool = newPool(mustValidator())
+
+// newPool returns a pool of copies of the Validator.
+func newPool(v *tageval.Validator) *sync.Pool {
+	return &sync.Pool{
+		New: func() interface{} {
+			return v.Copy()
+		},
+	}
+}
+
+// mustValidator returns a new Validator with the options, panicking if
+// it can't be created.
+func mustValidator(options ...tageval.Option) *tageval.Validator {
+	v, err := tageval.NewValidator(options...)
+	if err != nil {
+		panic(fmt.Sprintf("httpval: creating validator: %v", err))
+	}
+	return v
+}
+
+// Decode decodes the JSON body of the request into a T, and validates it
+// with a default Validator.  See DecodeWith.
+func Decode[T any](r *http.Request) (T, error) {
+	v := pool.Get().(*tageval.Validator)
+	defer pool.Put(v)
+	return DecodeWith[T](v, r)
+}
+
+// DecodeWith decodes the JSON body of the request into a T, and validates
+// it with the supplied Validator, using tageval's ValidateJSON.  A body
+// that can't be read or parsed, or is larger than MaxBodyBytes, yields a
+// *BodyError, and one that fails
+// validation a *ValidationError holding the Results.  Any other error
+// means the validation itself went wrong.  The messages of the Results
+// are in the languages of the request's Accept-Language header, if the
+// Validator has a Catalog.
+func DecodeWith[T any](v *tageval.Validator, r *http.Request) (T, error) {
+	var item T
+	data, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, MaxBodyBytes))
+	if err != nil {
+		return item, &BodyError{err}
+	}
+
+	// Check the syntax first, so that any error from the validation
+	// is known not to be the client's fault.
+	var raw json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return item, &BodyError{err}
+	}
+
+	langs, _, _ := language.ParseAcceptLanguage(r.Header.Get("Accept-Language"))
+	ok, res, err := v.Localized(langs...).ValidateJSON(data, &item)
+	if err != nil {
+		return item, err
+	}
+	if !ok {
+		return item, &ValidationError{res}
+	}
+	return item, nil
+}
+
+// WriteError writes the response for an error returned by Decode: a 400
+// problem listing the failed Results for a *ValidationError, a 413 for a
+// *BodyError for a body that is too large, a 400 for any other, and a 500
+// for anything else.  The error of a 500 is not described, as it is no
+// concern of the client's and may reveal the server's internals.
+func WriteError(w http.ResponseWriter, err error) {
+	var ve *ValidationError
+	var be *BodyError
+	var me *http.MaxBytesError
+	switch {
+	case errors.As(err, &ve):
+		WriteProblem(w, http.StatusBadRequest, "Validation failed", "",
+			ve.Results)
+	case errors.As(err, &me):
+		WriteProblem(w, http.StatusRequestEntityTooLarge,
+			"Request body too large", "", nil)
+	case errors.As(err, &be):
+		WriteProblem(w, http.StatusBadRequest, "Invalid request body",
+			be.Err.Error(), nil)
+	default:
+		WriteProblem(w, http.StatusInternalServerError, "Validation error",
+			"", nil)
+	}
+}
+
+// WriteProblem writes an RFC 7807 response with the given status, listing
+// the failed Results, if any.
+func WriteProblem(w http.ResponseWriter, status int, title, detail string,
+	res []tageval.Result) {
+	p := Problem{
+		Type:   "about:blank",
+		Title:  title,
+		Status: status,
+		Detail: detail,
+	}
+	for _, r := range res {
+		if r.Valid {
+			continue
+		}
+		p.Errors = append(p.Errors, ProblemError{
+			Name:     r.Name,
+			Pointer:  r.Path,
+			Rule:     string(r.Kind),
+			Expr:     r.Expr,
+			Severity: string(r.Severity),
+			Code:     r.Code,
+			Message:  r.Message,
+		})
+	}
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(p)
+}
+
+type contextKey[T any] struct{}
+
+// Middleware returns a handler that decodes and validates the request body
+// into a T before calling next, which may retrieve the value with
+// FromContext.  If decoding or validation fails, the problem response is
+// written instead, and next is not called.  The options are those for
+// creating the Validator, which is created once, here, and Middleware
+// panics if that fails, as for an invalid handler pattern.
+func Middleware[T any](next http.Handler, options ...tageval.Option) http.Handler {
+	vpool := newPool(mustValidator(options...))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v := vpool.Get().(*tageval.Validator)
+		item, err := DecodeWith[T](v, r)
+		vpool.Put(v)
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+		ctx := context.WithValue(r.Context(), contextKey[T]{}, item)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// FromContext returns the value decoded by Middleware.
+func FromContext[T any](ctx context.Context) (T, bool) {
+	item, ok := ctx.Value(contextKey[T]{}).(T)
+	return item, ok
+}
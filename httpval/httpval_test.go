@@ -0,0 +1,193 @@
+package httpval
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gdotgordon/tageval"
+	"golang.org/x/text/language"
+)
+
+type Order struct {
+	ID    string `json:"id" required:"true" regexp:"^[0-9]+$"`
+	Total int    `json:"total" expr:"> 0"`
+}
+
+func TestDecode(t *testing.T) {
+	r := httptest.NewRequest("POST", "/", strings.NewReader(`{"id": "17", "total": 5}`))
+	o, err := Decode[Order](r)
+	if err != nil {
+		t.Fatalf("decode failed with error: %v", err)
+	}
+	if o.ID != "17" || o.Total != 5 {
+		t.Fatalf("unexpected decoded value: %+v", o)
+	}
+
+	r = httptest.NewRequest("POST", "/", strings.NewReader(`{"total": 0}`))
+	_, err = Decode[Order](r)
+	var ve *ValidationError
+	if !errors.As(err, &ve) || len(ve.Results) != 2 {
+		t.Fatalf("did not receive expected validation error: %v", err)
+	}
+
+	// Failures that are only warnings don't reject the body.
+	type Note struct {
+		Text string `json:"text" expr:"Text.length < 5" severity:"warning"`
+	}
+	r = httptest.NewRequest("POST", "/", strings.NewReader(`{"text": "too long"}`))
+	if _, err = Decode[Note](r); err != nil {
+		t.Fatalf("decode failed with error: %v", err)
+	}
+
+	r = httptest.NewRequest("POST", "/", strings.NewReader(`{"id": `))
+	_, err = Decode[Order](r)
+	var be *BodyError
+	if !errors.As(err, &be) {
+		t.Fatalf("did not receive expected body error: %v", err)
+	}
+
+	body := `{"id": "17", "total": 5}` + strings.Repeat(" ", MaxBodyBytes)
+	r = httptest.NewRequest("POST", "/", strings.NewReader(body))
+	_, err = Decode[Order](r)
+	var me *http.MaxBytesError
+	if !errors.As(err, &be) || !errors.As(err, &me) {
+		t.Fatalf("did not receive expected body error: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	WriteError(rec, err)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("unexpected response code %d", rec.Code)
+	}
+
+	// The cause of a server error is not given to the client.
+	rec = httptest.NewRecorder()
+	WriteError(rec, errors.New("engine failure"))
+	if rec.Code != http.StatusInternalServerError ||
+		strings.Contains(rec.Body.String(), "engine") {
+		t.Fatalf("unexpected response %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var got Order
+	h := Middleware[Order](http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			var ok bool
+			got, ok = FromContext[Order](r.Context())
+			if !ok {
+				t.Fatalf("decoded value missing from context")
+			}
+			w.WriteHeader(http.StatusCreated)
+		}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/",
+		strings.NewReader(`{"id": "17", "total": 5}`)))
+	if rec.Code != http.StatusCreated || got.ID != "17" {
+		t.Fatalf("unexpected response %d for %+v", rec.Code, got)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/",
+		strings.NewReader(`{"id": "x", "total": 5, "extra": true}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("unexpected response code %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != ProblemContentType {
+		t.Fatalf("unexpected content type '%s'", ct)
+	}
+	var p Problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatalf("bad problem response: %v", err)
+	}
+	if p.Status != http.StatusBadRequest || len(p.Errors) != 2 ||
+		p.Errors[0].Pointer != "/extra" || p.Errors[1].Pointer != "/id" {
+		t.Fatalf("unexpected problem response: %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader(``)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("unexpected response code %d", rec.Code)
+	}
+}
+
+func TestLocalized(t *testing.T) {
+	c := tageval.NewCatalog(language.English)
+	c.Add(language.English, "Order.Total.expr", "Total must be positive")
+	c.Add(language.German, "Order.Total.expr", "Summe muss positiv sein")
+	h := Middleware[Order](http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {}), tageval.WithCatalog(c))
+
+	for lang, expected := range map[string]string{
+		"de-AT, en;q=0.5": "Summe muss positiv sein",
+		"fr":              "Total must be positive",
+		"":                "Total must be positive",
+	} {
+		rec := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/",
+			strings.NewReader(`{"id": "17", "total": 0}`))
+		r.Header.Set("Accept-Language", lang)
+		h.ServeHTTP(rec, r)
+		var p Problem
+		if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+			t.Fatalf("bad problem response: %v", err)
+		}
+		if len(p.Errors) != 1 || p.Errors[0].Message != expected ||
+			p.Errors[0].Code != "Order.Total.expr" {
+			t.Fatalf("unexpected problem response for %q: %s", lang,
+				rec.Body.String())
+		}
+	}
+}
+
+type Event struct {
+	Name string    `json:"name" expr:"Name.length > 0"`
+	At   time.Time `json:"at" expr:"At.getTime() > 0"`
+}
+
+// TestConcurrent is meant for the race detector: the pooled Validators
+// must not share an engine, including through the time.Time mapping.
+func TestConcurrent(t *testing.T) {
+	h := Middleware[Event](http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {}))
+	const body = `{"name": "launch", "at": "2024-01-01T00:00:00Z"}`
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+				if _, err := Decode[Event](r); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				rec := httptest.NewRecorder()
+				h.ServeHTTP(rec, httptest.NewRequest("POST", "/",
+					strings.NewReader(body)))
+				if rec.Code != http.StatusOK {
+					errs <- errors.New(rec.Body.String())
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("concurrent decode failed: %v", err)
+	}
+}
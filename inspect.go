@@ -0,0 +1,194 @@
+package tageval
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/robertkrimen/otto"
+)
+
+// A JSValue describes a value as JavaScript sees it, to help with
+// debugging expressions and type mappings.
+type JSValue struct {
+	// Expr is the JavaScript that reaches the value, such as
+	// "doc.lines[0].qty".
+	Expr string
+
+	// Type is the JavaScript type: the typeof result for primitives,
+	// "null", or the class of an object, such as "Object", "Array" or
+	// "Date".
+	Type string
+
+	// Text is the value as JSON for strings, plain objects and arrays,
+	// and as the JavaScript string conversion otherwise.
+	Text string
+
+	// GoType is the type of the Go value that was converted, if known,
+	// and Mapped says whether a TypeMapper did the conversion.
+	GoType reflect.Type
+	Mapped bool
+}
+
+// identRE matches the property names that may follow a ".".
+var identRE = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// Bind sets a variable in the Validator's JavaScript engine to a Go
+// value, converted just as a field's value is for its expr tag, with any
+// TypeMapper for its type applied.  The variable stays set for later
+// calls to Eval, but validation binds each field's name in the same
+// engine, overwriting any variable of that name.  With the Sandbox
+// option, the variable is visible to every expression.
+func (v Validator) Bind(name string, val interface{}) error {
+	_, err := v.eval.define(name, val)
+	return err
+}
+
+// Eval evaluates JavaScript source in the Validator's engine, where any
+// bound variables are visible, and describes the result.  Unlike expr
+// tags, the script is not cached.
+func (v Validator) Eval(src string) (JSValue, error) {
+	v.eval.field, v.eval.expr = "", src
+	res, err := v.eval.vm.Run(src)
+	if err != nil {
+		return JSValue{}, err
+	}
+	jv := JSValue{Expr: src}
+	jv.Type, jv.Text = v.eval.describe(res)
+	return jv, nil
+}
+
+// Inspect binds a Go value to name, as Bind does, and describes the
+// variable along with every value reachable from it through exported
+// struct fields, maps with string keys, slices and arrays, in that
+// order, as they appear in JavaScript.  Only the variable itself is
+// subject to type mapping, as with expr tags, so nested values may be
+// converted differently.
+func (v Validator) Inspect(name string, val interface{}) ([]JSValue, error) {
+	mapped, err := v.eval.define(name, val)
+	if err != nil {
+		return nil, err
+	}
+	var res []JSValue
+	err = v.inspect(name, reflect.ValueOf(val), mapped, &res,
+		make(map[uintptr]bool))
+	return res, err
+}
+
+func (v Validator) inspect(expr string, val reflect.Value, mapped bool,
+	res *[]JSValue, seen map[uintptr]bool) error {
+	for val.Kind() == reflect.Interface && !val.IsNil() {
+		val = val.Elem()
+	}
+	if val.Kind() == reflect.Ptr && !val.IsNil() {
+		// Pointers may form cycles.
+		if seen[val.Pointer()] {
+			return nil
+		}
+		seen[val.Pointer()] = true
+		defer delete(seen, val.Pointer())
+	}
+
+	ov, err := v.eval.vm.Run(expr)
+	if err != nil {
+		return fmt.Errorf("inspecting %s: %v", expr, err)
+	}
+	jv := JSValue{Expr: expr, Mapped: mapped}
+	if val.IsValid() {
+		jv.GoType = val.Type()
+	}
+	jv.Type, jv.Text = v.eval.describe(ov)
+	*res = append(*res, jv)
+	if mapped {
+		return nil
+	}
+
+	val = reflect.Indirect(val)
+	switch val.Kind() {
+	case reflect.Struct:
+		if val.Type() == timeType {
+			return nil
+		}
+		for i := 0; i < val.NumField(); i++ {
+			f := val.Type().Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			err := v.inspect(property(expr, f.Name), val.Field(i), false,
+				res, seen)
+			if err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map:
+		if val.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		keys := val.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].String() < keys[j].String()
+		})
+		for _, k := range keys {
+			err := v.inspect(property(expr, k.String()), val.MapIndex(k),
+				false, res, seen)
+			if err != nil {
+				return err
+			}
+		}
+
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < val.Len(); i++ {
+			err := v.inspect(expr+"["+strconv.Itoa(i)+"]", val.Index(i), false,
+				res, seen)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// property returns the JavaScript accessing a property of an object.
+func property(expr, name string) string {
+	if identRE.MatchString(name) {
+		return expr + "." + name
+	}
+	return expr + "[" + strconv.Quote(name) + "]"
+}
+
+// describe returns the JavaScript type of a value and its text.
+func (e *evaluator) describe(val otto.Value) (string, string) {
+	var typ string
+	switch {
+	case val.IsUndefined():
+		return "undefined", "undefined"
+	case val.IsNull():
+		return "null", "null"
+	case val.IsBoolean():
+		return "boolean", val.String()
+	case val.IsNumber():
+		return "number", val.String()
+	case val.IsString():
+		typ = "string"
+	case val.IsFunction():
+		return "function", val.String()
+	default:
+		typ = val.Class()
+		switch typ {
+		case "GoSlice", "GoArray":
+			// Go slices and arrays act as JavaScript arrays.
+			typ = "Array"
+		case "Object", "Array":
+		default:
+			return typ, val.String()
+		}
+	}
+	text, err := e.vm.Call("JSON.stringify", nil, val)
+	if err != nil || !text.IsString() {
+		return typ, val.String()
+	}
+	return typ, text.String()
+}
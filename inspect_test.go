@@ -0,0 +1,84 @@
+package tageval
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInspect(t *testing.T) {
+	type Line struct {
+		Qty    int       `json:"qty"`
+		Placed time.Time `json:"placed"`
+	}
+	type Order struct {
+		ID    string
+		Lines []Line
+		Attrs map[string]interface{}
+	}
+	placed := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	o := Order{ID: "7", Lines: []Line{{Qty: 2, Placed: placed}},
+		Attrs: map[string]interface{}{"gift wrap": true, "note": nil}}
+
+	v, _ := NewValidator()
+	res, err := v.Inspect("doc", o)
+	if err != nil {
+		t.Fatalf("inspection failed with error: %v", err)
+	}
+	expected := []JSValue{
+		{Expr: "doc", Type: "Object"},
+		{Expr: "doc.ID", Type: "string", Text: `"7"`},
+		{Expr: "doc.Lines", Type: "Array"},
+		{Expr: "doc.Lines[0]", Type: "Object"},
+		{Expr: "doc.Lines[0].Qty", Type: "number", Text: "2"},
+		{Expr: "doc.Lines[0].Placed", Type: "Object"},
+		{Expr: "doc.Attrs", Type: "Object"},
+		{Expr: `doc.Attrs["gift wrap"]`, Type: "boolean", Text: "true"},
+		{Expr: "doc.Attrs.note", Type: "undefined", Text: "undefined"},
+	}
+	if len(res) != len(expected) {
+		t.Fatalf("expected %d values, got %d: %+v", len(expected), len(res),
+			res)
+	}
+	for i, e := range expected {
+		r := res[i]
+		if r.Expr != e.Expr || r.Type != e.Type ||
+			(e.Text != "" && r.Text != e.Text) || r.Mapped {
+			t.Fatalf("value %d: expected %+v, got %+v", i, e, r)
+		}
+	}
+
+	// At the top level, time.Time is mapped to a Date.
+	res, err = v.Inspect("placed", placed)
+	if err != nil {
+		t.Fatalf("inspection failed with error: %v", err)
+	}
+	if len(res) != 1 || res[0].Type != "Date" || !res[0].Mapped ||
+		res[0].GoType != timeType {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
+
+func TestEval(t *testing.T) {
+	v, _ := NewValidator()
+	if err := v.Bind("Total", 12); err != nil {
+		t.Fatalf("binding failed with error: %v", err)
+	}
+	for src, expected := range map[string]JSValue{
+		"Total > 10":           {Type: "boolean", Text: "true"},
+		"[Total, 'a']":         {Type: "Array", Text: `[12,"a"]`},
+		"({n: Total})":         {Type: "Object", Text: `{"n":12}`},
+		"new Date(0).getDay()": {Type: "number", Text: "4"},
+		"null":                 {Type: "null", Text: "null"},
+	} {
+		jv, err := v.Eval(src)
+		if err != nil {
+			t.Fatalf("%s: evaluation failed with error: %v", src, err)
+		}
+		if jv.Type != expected.Type || jv.Text != expected.Text {
+			t.Fatalf("%s: expected %+v, got %+v", src, expected, jv)
+		}
+	}
+	if _, err := v.Eval("Total >"); err == nil {
+		t.Fatalf("expected syntax error")
+	}
+}
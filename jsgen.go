@@ -0,0 +1,434 @@
+package tageval
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/robertkrimen/otto/ast"
+	"github.com/robertkrimen/otto/parser"
+)
+
+// WriteJSModule writes a standalone ES module for the Go type, so that a
+// web frontend can apply exactly the rules the Go backend enforces.  The
+// module exports a function
+//
+//	validate(obj, opts = {})
+//
+// which applies the expr and regexp rules to a JSON-shaped object, such as
+// the result of JSON.parse(), and returns an array of failed results (or
+// all results, if opts.showSuccesses is set).  Each result is an object
+// with name, path, kind, expr, value, valid, severity and code properties,
+// mirroring a Result, plus an error property if the expression threw an
+// exception.  The value of a sensitive field is given as Redacted, with a
+// redacted property set, though unlike in Go, its nested fields are not
+// masked.
+//
+// Properties are looked up by the names given by the Validator's
+// Convention, and the serialization rules for skipped and empty fields are
+// obeyed.  As in Go, the rules of a null or missing pointer are not run,
+// and a null slice or map is seen as empty.  Shortcut relational
+// expressions are expanded as usual, and time.Time values are mapped to a
+// JavaScript Date, as they are by the built-in TimeMapper.  Custom
+// TypeMappers are Go functions, and are not available to the module.  The
+// now variable is the time validate was called, or opts.now if given, as a
+// WithClock would give it in Go.  Regular expressions are translated from
+// RE2 to JavaScript syntax.  Note that modules run in strict mode, so
+// expressions that assign to undeclared variables will fail there.
+func (v Validator) WriteJSModule(w io.Writer, t reflect.Type) error {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return fmt.Errorf("cannot generate a JavaScript module for %v", t)
+	}
+
+	jg := &jsGen{v: v, rules: v.currentRules(), funcs: make(map[reflect.Type]string),
+		taken: make(map[string]bool), patternIdx: make(map[string]int)}
+	root := jg.funcFor(t)
+	for len(jg.queue) > 0 {
+		st := jg.queue[0]
+		jg.queue = jg.queue[1:]
+		if err := jg.structFunc(st); err != nil {
+			return err
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "// Code generated by tageval from %v. DO NOT EDIT.\n\n", t)
+	b.WriteString("const patterns = [\n")
+	for _, p := range jg.patterns {
+		fmt.Fprintf(&b, "  new RegExp(%s, \"u\"),\n", jsQuote(p))
+	}
+	b.WriteString("];\n")
+	b.WriteString(jsRuntime)
+	b.WriteString(jg.body.String())
+	fmt.Fprintf(&b, `
+export function validate(obj, opts = {}) {
+  const results = [];
+  now = opts.now === undefined ? new Date() : new Date(opts.now);
+  %s(obj, "", results, opts);
+  return results;
+}
+`, root)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// The helper functions shared by the generated validation functions.
+const jsRuntime = `
+let now;
+
+function check(results, opts, rule, test, value) {
+  let valid;
+  const shown = rule.redacted ? "[REDACTED]" : value;
+  try {
+    valid = Boolean(test(value));
+  } catch (e) {
+    results.push(Object.assign({}, rule, {value: shown, valid: false, error: String(e)}));
+    return;
+  }
+  if (!valid || opts.showSuccesses) {
+    results.push(Object.assign({}, rule, {value: shown, valid: valid}));
+  }
+}
+
+function missing(results, name, path, severity, code) {
+  results.push({name: name, path: path, kind: "required", expr: "required", valid: false,
+    severity: severity, code: code});
+}
+
+function isEmpty(value) {
+  if (value === undefined || value === null || value === false ||
+      value === 0 || value === "") {
+    return true;
+  }
+  if (Array.isArray(value)) {
+    return value.length === 0;
+  }
+  return typeof value === "object" && Object.keys(value).length === 0;
+}
+
+function toDate(value) {
+  return value === undefined || value === null ? value : new Date(value);
+}
+
+function toRegexpString(value) {
+  return typeof value === "string" ? value : String(value);
+}
+
+function pointerTo(path, token) {
+  return path + "/" + String(token).replace(/~/g, "~0").replace(/\//g, "~1");
+}
+`
+
+// A jsGen accumulates the generated functions for each struct type.
+type jsGen struct {
+	v          Validator
+	rules      *RuleSet
+	funcs      map[reflect.Type]string
+	taken      map[string]bool
+	queue      []reflect.Type
+	patterns   []string
+	patternIdx map[string]int
+	body       strings.Builder
+}
+
+// funcFor returns the name of the validation function for a struct type,
+// queueing it for generation if need be.
+func (jg *jsGen) funcFor(t reflect.Type) string {
+	if name, ok := jg.funcs[t]; ok {
+		return name
+	}
+	base := "validate" + defNameRE.ReplaceAllString(t.Name(), "_")
+	if t.Name() == "" {
+		base = "validateStruct"
+	}
+	name := base
+	for i := 2; jg.taken[name]; i++ {
+		name = base + strconv.Itoa(i)
+	}
+	jg.taken[name] = true
+	jg.funcs[t] = name
+	jg.queue = append(jg.queue, t)
+	return name
+}
+
+// pattern returns the index of the translated regexp in the module's
+// table of patterns.
+func (jg *jsGen) pattern(p string) (int, error) {
+	if i, ok := jg.patternIdx[p]; ok {
+		return i, nil
+	}
+	src, err := jsRegexp(p)
+	if err != nil {
+		return 0, err
+	}
+	jg.patterns = append(jg.patterns, src)
+	jg.patternIdx[p] = len(jg.patterns) - 1
+	return len(jg.patterns) - 1, nil
+}
+
+// structFunc generates the validation function for a struct type.
+func (jg *jsGen) structFunc(t reflect.Type) error {
+	b := &jg.body
+	fmt.Fprintf(b, "\nfunction %s(obj, path, results, opts) {\n", jg.funcs[t])
+	b.WriteString("  if (obj === null || typeof obj !== \"object\") {\n")
+	b.WriteString("    return;\n  }\n")
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		fi := FieldInfo{Name: f.Name}
+		if jg.v.conv != nil {
+			fi = jg.v.conv.Field(f)
+		}
+		if fi.Skip {
+			continue
+		}
+		if fi.Inline {
+			et := f.Type
+			if et.Kind() == reflect.Ptr {
+				et = et.Elem()
+			}
+			fmt.Fprintf(b, "  %s(obj, path, results, opts);\n", jg.funcFor(et))
+			continue
+		}
+		if jg.v.conv != nil && !f.IsExported() {
+			continue
+		}
+		if err := jg.field(t, f, fi); err != nil {
+			return fmt.Errorf("field '%s': %v", f.Name, err)
+		}
+	}
+	b.WriteString("}\n")
+	return nil
+}
+
+// field generates the rules and traversal for a single struct field.
+func (jg *jsGen) field(st reflect.Type, f reflect.StructField,
+	fi FieldInfo) error {
+	var rules strings.Builder
+	var redacted string
+	if IsSensitive(f.Tag) {
+		redacted = ", redacted: true"
+	}
+	sev, err := parseSeverity(f.Tag.Get(SeverityTag))
+	if err != nil {
+		return err
+	}
+	codes, err := parseCode(f.Tag.Get(CodeTag))
+	if err != nil {
+		return err
+	}
+	rule := func(kind RuleKind, expr string) string {
+		return fmt.Sprintf("{name: %s, path: fpath, kind: %s, expr: %s, severity: %s, code: %s%s}",
+			jsQuote(f.Name), jsQuote(string(kind)), jsQuote(expr),
+			jsQuote(string(sev.of(kind))),
+			jsQuote(ruleCode(codes, st, f, kind)), redacted)
+	}
+
+	if exprTag := jg.v.rule(jg.rules, st, f, ExprRule); exprTag != "" {
+		expr := ExpandShortcut(f.Name, exprTag)
+		body, err := jsFunctionBody(expr)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&rules, "    check(results, opts, %s, function (%s) {\n",
+			rule(ExprRule, expr), f.Name)
+		fmt.Fprintf(&rules, "      %s\n", body)
+		fmt.Fprintf(&rules, "    }, %s);\n", jsFieldValue(f.Type))
+	}
+	if regexpTag := jg.v.rule(jg.rules, st, f, RegexpRule); regexpTag != "" {
+		idx, err := jg.pattern(regexpTag)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&rules, "    check(results, opts, %s, function (value) {\n",
+			rule(RegexpRule, regexpTag))
+		fmt.Fprintf(&rules, "      return patterns[%d].test(toRegexpString(value));\n",
+			idx)
+		fmt.Fprintf(&rules, "    }, %s);\n", jsZeroValue(f.Type))
+	}
+	// The Go validator skips the rules of a nil pointer or interface,
+	// which is what null and missing properties decode to.
+	if k := f.Type.Kind(); (k == reflect.Ptr || k == reflect.Interface) &&
+		rules.Len() > 0 {
+		guarded := "    if (value !== undefined && value !== null) {\n" +
+			indentJS(rules.String(), "  ") + "    }\n"
+		rules.Reset()
+		rules.WriteString(guarded)
+	}
+	walk := jg.walkCode(f.Type, "value", "fpath", "    ", 0)
+	required, _ := strconv.ParseBool(jg.v.rule(jg.rules, st, f, RequiredRule))
+	if rules.Len() == 0 && walk == "" && !required {
+		return nil
+	}
+
+	b := &jg.body
+	fmt.Fprintf(b, "  {\n    const value = obj[%s];\n", jsQuote(fi.Name))
+	fmt.Fprintf(b, "    const fpath = path + %s;\n", jsQuote(pointerTo("", fi.Name)))
+
+	// As in the Go validator, rules aren't run for a missing value,
+	// or for an empty one that would be omitted.
+	omit := fi.OmitEmpty && jg.v.conv != nil && f.Type.Kind() != reflect.Struct
+	switch {
+	case required:
+		fmt.Fprintf(b, "    if (value === undefined) {\n")
+		fmt.Fprintf(b, "      missing(results, %s, fpath, %s, %s);\n",
+			jsQuote(f.Name), jsQuote(string(sev.of(RequiredRule))),
+			jsQuote(ruleCode(codes, st, f, RequiredRule)))
+		if rules.Len() > 0 {
+			if omit {
+				b.WriteString("    } else if (!isEmpty(value)) {\n")
+			} else {
+				b.WriteString("    } else {\n")
+			}
+			b.WriteString(indentJS(rules.String(), "  "))
+		}
+		b.WriteString("    }\n")
+	case omit && rules.Len() > 0:
+		b.WriteString("    if (!isEmpty(value)) {\n")
+		b.WriteString(indentJS(rules.String(), "  "))
+		b.WriteString("    }\n")
+	default:
+		b.WriteString(rules.String())
+	}
+	b.WriteString(walk)
+	b.WriteString("  }\n")
+	return nil
+}
+
+// walkCode returns the code to descend into a value of the Go type, to
+// reach any nested structs, or the empty string if there are none.  The
+// depth keeps the variable names of nested loops apart.
+func (jg *jsGen) walkCode(t reflect.Type, value, path, indent string,
+	depth int) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if !hasStruct(t, make(map[reflect.Type]bool)) {
+		return ""
+	}
+	in := indent + "  "
+	key := "k" + strconv.Itoa(depth)
+	elem := "e" + strconv.Itoa(depth)
+	switch t.Kind() {
+	case reflect.Struct:
+		return fmt.Sprintf("%s%s(%s, %s, results, opts);\n", indent,
+			jg.funcFor(t), value, path)
+	case reflect.Slice, reflect.Array:
+		inner := jg.walkCode(t.Elem(), elem,
+			"pointerTo("+path+", "+key+")", in+"  ", depth+1)
+		return fmt.Sprintf("%sif (Array.isArray(%s)) {\n"+
+			"%s%s.forEach(function (%s, %s) {\n%s%s});\n%s}\n",
+			indent, value, in, value, elem, key, inner, in, indent)
+	case reflect.Map:
+		inner := jg.walkCode(t.Elem(), elem,
+			"pointerTo("+path+", "+key+")", in+"  ", depth+1)
+		return fmt.Sprintf("%sif (%s !== null && typeof %s === \"object\") {\n"+
+			"%sObject.keys(%s).forEach(function (%s) {\n"+
+			"%s  const %s = %s[%s];\n%s%s});\n%s}\n",
+			indent, value, value, in, value, key, in, elem, value, key,
+			inner, in, indent)
+	}
+	return ""
+}
+
+// hasStruct reports whether values of the type may contain structs that
+// need validating.
+func hasStruct(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return hasStruct(t.Elem(), seen)
+	case reflect.Struct:
+		return t != timeType
+	}
+	return false
+}
+
+// jsFieldValue returns the expression for the value bound to the field
+// name in an expression.
+func jsFieldValue(t reflect.Type) string {
+	if t == timeType || (t.Kind() == reflect.Ptr && t.Elem() == timeType) {
+		return "toDate(value)"
+	}
+	return jsZeroValue(t)
+}
+
+// jsZeroValue returns the expression for a field value, where absent
+// properties take the JavaScript form of the Go zero value, which is
+// what the Go validator would see.  A nil slice or map, which
+// encoding/json writes as null, is seen as empty, as it is in Go.
+func jsZeroValue(t reflect.Type) string {
+	var zero string
+	switch t.Kind() {
+	case reflect.Slice:
+		return "value === undefined || value === null ? [] : value"
+	case reflect.Map:
+		return "value === undefined || value === null ? {} : value"
+	case reflect.String:
+		zero = `""`
+	case reflect.Bool:
+		zero = "false"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16,
+		reflect.Uint32, reflect.Uint64, reflect.Uintptr, reflect.Float32,
+		reflect.Float64:
+		zero = "0"
+	default:
+		return "value"
+	}
+	return "value === undefined ? " + zero + " : value"
+}
+
+// jsFunctionBody turns an expression, which may consist of several
+// statements, into the body of a function returning the value of the
+// final statement, as the otto engine does when running it.
+func jsFunctionBody(expr string) (string, error) {
+	prog, err := parser.ParseFile(nil, "", expr, 0)
+	if err != nil {
+		return "", err
+	}
+	if len(prog.Body) == 0 {
+		return "return undefined;", nil
+	}
+	if es, ok := prog.Body[len(prog.Body)-1].(*ast.ExpressionStatement); ok {
+		// Indexes are 1-based.
+		at := int(es.Idx0()) - 1
+		body := strings.TrimSpace(expr[:at] + "return " + expr[at:])
+		if !strings.HasSuffix(body, ";") {
+			body += ";"
+		}
+		return body, nil
+	}
+
+	// Only eval() can produce the completion value of other statements.
+	return "return eval(" + jsQuote(expr) + ");", nil
+}
+
+// indentJS indents each line of the code.
+func indentJS(code, indent string) string {
+	lines := strings.SplitAfter(code, "\n")
+	for i, l := range lines {
+		if l != "" {
+			lines[i] = indent + l
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+// jsQuote quotes the string as a JavaScript string literal.
+func jsQuote(s string) string {
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(b.String(), "\n")
+}
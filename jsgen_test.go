@@ -0,0 +1,193 @@
+package tageval
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSRegexp(t *testing.T) {
+	tests := []struct {
+		re2, js string
+	}{
+		{`^[A-Z]{3}-[0-9]+$`, `^[A-Z]{3}-[0-9]+$`},
+		{`(?i)ab+`, `[Aa][Bb]+`},
+		{`(?P<year>\d{4})-(\d{2})`, `(?<year>[0-9]{4})-([0-9]{2})`},
+		{`\pL`, `[A-Za-z\u{AA}`},
+		{`[[:space:]]`, `[\u{9}-\u{D}\u{20}]`},
+		{`\Qa.b\E`, `a\.b`},
+		{`(?s).`, `[\s\S]`},
+		{`(?m)^x$`, `(?:^|(?<=\n))x(?:$|(?=\n))`},
+		{`a/b|c`, `a\/b|c`},
+		{`x(?:ab|cd)*?`, `x(?:ab|cd)*?`},
+		{`a{2,}b{1,3}`, `a{2,}b{1,3}`},
+	}
+	for _, test := range tests {
+		js, err := jsRegexp(test.re2)
+		if err != nil {
+			t.Fatalf("translation of '%s' failed with error: %v", test.re2, err)
+		}
+		if !strings.HasPrefix(js, test.js) {
+			t.Fatalf("translation of '%s' was '%s', expected '%s'",
+				test.re2, js, test.js)
+		}
+	}
+}
+
+func TestJSFunctionBody(t *testing.T) {
+	tests := []struct {
+		expr, body string
+	}{
+		{"A > 5", "return A > 5;"},
+		{"var sum = P[0] + P[1]; sum == 10", "var sum = P[0] + P[1]; return sum == 10;"},
+		{"if (A) { true } else { false }",
+			`return eval("if (A) { true } else { false }");`},
+	}
+	for _, test := range tests {
+		body, err := jsFunctionBody(test.expr)
+		if err != nil {
+			t.Fatalf("conversion of '%s' failed with error: %v", test.expr, err)
+		}
+		if body != test.body {
+			t.Fatalf("conversion of '%s' was '%s', expected '%s'",
+				test.expr, body, test.body)
+		}
+	}
+	if _, err := jsFunctionBody("this omelet has no !*@&^% mushrooms"); err == nil {
+		t.Fatalf("did not receive expected parse error")
+	}
+}
+
+func TestWriteJSModule(t *testing.T) {
+	v, _ := NewValidator()
+	var buf bytes.Buffer
+	if err := v.WriteJSModule(&buf, reflect.TypeOf(&Order{})); err != nil {
+		t.Fatalf("module generation failed with error: %v", err)
+	}
+	mod := buf.String()
+	for _, want := range []string{
+		`new RegExp("^[A-Z]{3}-[0-9]+$", "u")`,
+		"export function validate(obj, opts = {}) {",
+		"now = opts.now === undefined ? new Date() : new Date(opts.now);",
+		"function validateOrder(obj, path, results, opts) {",
+		"function validateLine(obj, path, results, opts) {",
+		`const value = obj["qty"];`,
+		"function (Quantity) {\n      return Quantity > 0;",
+		"function (Status) {\n        return Status != 'void';",
+		"validateLine(e0, pointerTo(fpath, k0), results, opts);",
+		"validateOrder(value, fpath, results, opts);",
+	} {
+		if !strings.Contains(mod, want) {
+			t.Fatalf("module does not contain '%s':\n%s", want, mod)
+		}
+	}
+	if strings.Contains(mod, "Private") {
+		t.Fatalf("module contains skipped field:\n%s", mod)
+	}
+}
+
+type JSCompare struct {
+	Lines  []int          `json:"lines" expr:"Lines.length <= 2"`
+	Counts map[string]int `json:"counts" expr:"Object.keys(Counts).length < 2"`
+	Limit  *int           `json:"limit" expr:"> 0"`
+	Code   string         `json:"code,omitempty" regexp:"^[a-z]+$"`
+	At     time.Time      `json:"at" expr:"At.getFullYear() > 2000"`
+	Next   *time.Time     `json:"next" expr:"Next.getMonth() == 0"`
+	Items  []Line         `json:"items"`
+	Sub    *Line          `json:"sub"`
+	Any    interface{}    `json:"any" expr:"Any != 3"`
+}
+
+// TestJSModuleAgrees runs the module under node, if installed, on the
+// JSON encoding of values, and checks it fails the same rules as Validate.
+func TestJSModuleAgrees(t *testing.T) {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node not found")
+	}
+	v, _ := NewValidator()
+	var buf bytes.Buffer
+	if err := v.WriteJSModule(&buf, reflect.TypeOf(JSCompare{})); err != nil {
+		t.Fatalf("module generation failed with error: %v", err)
+	}
+	dir := t.TempDir()
+	runner := `import {validate} from "./module.mjs";
+import {readFileSync} from "fs";
+const docs = JSON.parse(readFileSync(0, "utf8"));
+console.log(JSON.stringify(docs.map(d => validate(d))));
+`
+	if err := os.WriteFile(filepath.Join(dir, "module.mjs"), buf.Bytes(),
+		0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "run.mjs"), []byte(runner),
+		0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	before, zero, five := at.Add(-time.Hour), 0, 5
+	items := []JSCompare{
+		{},
+		{Lines: []int{1}, Counts: map[string]int{"a": 1}, Limit: &five,
+			Code: "abc", At: at, Next: &at, Items: []Line{{"ABC-1", 1}},
+			Sub: &Line{"ABC-2", 2}, Any: 2},
+		{Lines: []int{1, 2, 3}, Counts: map[string]int{"a": 1, "b": 2},
+			Limit: &zero, Code: "ABC", At: at, Next: &before,
+			Items: []Line{{"x", 0}}, Sub: &Line{}, Any: 3},
+	}
+	var docs []json.RawMessage
+	for _, item := range items {
+		data, err := json.Marshal(item)
+		if err != nil {
+			t.Fatal(err)
+		}
+		docs = append(docs, data)
+	}
+	input, _ := json.Marshal(docs)
+	cmd := exec.Command(node, "run.mjs")
+	cmd.Dir = dir
+	cmd.Stdin = bytes.NewReader(input)
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("running module failed: %v", err)
+	}
+	var jsRes [][]struct {
+		Path  string `json:"path"`
+		Expr  string `json:"expr"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(out, &jsRes); err != nil {
+		t.Fatalf("bad module output %s: %v", out, err)
+	}
+
+	for i, item := range items {
+		_, res, err := v.Validate(item)
+		if err != nil {
+			t.Fatalf("validation failed with error: %v", err)
+		}
+		var goFailed, jsFailed []string
+		for _, r := range res {
+			goFailed = append(goFailed, r.Path+" "+r.Expr)
+		}
+		for _, r := range jsRes[i] {
+			if r.Error != "" {
+				t.Fatalf("module threw for %s: %s", docs[i], r.Error)
+			}
+			jsFailed = append(jsFailed, r.Path+" "+r.Expr)
+		}
+		sort.Strings(goFailed)
+		sort.Strings(jsFailed)
+		if !reflect.DeepEqual(goFailed, jsFailed) {
+			t.Fatalf("for %s, Go failed %q, module failed %q", docs[i],
+				goFailed, jsFailed)
+		}
+	}
+}
@@ -0,0 +1,399 @@
+package tageval
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// ValidateJSON decodes the JSON document in data into target, which must
+// be a non-nil pointer, and validates the result.  Unlike calling
+// json.Unmarshal followed by Validate, problems with the document itself,
+// such as unknown properties or values of the wrong type, are reported
+// as failed Results of kind DecodeRule alongside the rule failures, each
+// with the JSON pointer to the offending value.  The decoder also keeps
+// track of which properties were present, so that a `required:"true"`
+// field fails only if it was absent from the document, rather than when
+// it holds the zero value.
+//
+// JSON serialization rules are always obeyed here, whatever Convention
+// the Validator was created with.  An error is returned only if the
+// document is not well-formed JSON, or if something went wrong with the
+// validation itself.
+func (v Validator) ValidateJSON(data []byte, target interface{}) (bool,
+	[]Result, error) {
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return false, nil, fmt.Errorf("supplied target (%v) is not a non-nil pointer",
+			target)
+	}
+
+	// First decode into a generic document, to find out what is there.
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return false, nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return false, nil, errors.New("invalid JSON: data after top-level value")
+	}
+
+	w := &walk{safe: true, present: make(map[string]bool)}
+	v.checkJSON(doc, rv.Type().Elem(), "", "", w)
+	checked := len(w.res)
+	code := DefaultCode(rv.Type().Elem().Name(), "", DecodeRule)
+
+	// Now decode for real.  Type errors have already been reported
+	// in detail, anything else is reported against the whole document.
+	if err := json.Unmarshal(data, target); err != nil {
+		var ute *json.UnmarshalTypeError
+		if !errors.As(err, &ute) || checked == 0 {
+			w.res = append(w.res, Result{
+				Path:     jsonErrorPath(ute),
+				Type:     rv.Type().Elem(),
+				Kind:     DecodeRule,
+				Expr:     err.Error(),
+				Severity: SeverityError,
+			})
+		}
+	}
+	for i := range w.res {
+		if w.res[i].Code == "" {
+			w.res[i].Code = code
+		}
+	}
+
+	jv := v
+	jv.conv = JSONConvention
+	return jv.runWalk(rv.Elem(), w)
+}
+
+// checkJSON walks the generic document alongside the Go type it is to be
+// decoded into, recording the path of each value present and reporting
+// unknown properties and type mismatches.  The name is that of the
+// nearest enclosing Go struct field.
+func (v Validator) checkJSON(doc interface{}, t reflect.Type,
+	name, path string, w *walk) {
+	w.present[path] = true
+	if doc == nil {
+		// Null is acceptable for anything, and leaves the value alone.
+		return
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	pt := reflect.PointerTo(t)
+	if pt.Implements(jsonUnmarshalerType) {
+		return
+	}
+	if pt.Implements(textUnmarshalerType) {
+		if _, ok := doc.(string); !ok {
+			w.mismatch(doc, t, name, path)
+		}
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		if t.NumMethod() != 0 {
+			w.mismatch(doc, t, name, path)
+		}
+
+	case reflect.Bool:
+		if _, ok := doc.(bool); !ok {
+			w.mismatch(doc, t, name, path)
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		n, ok := doc.(json.Number)
+		if ok {
+			i, err := strconv.ParseInt(string(n), 10, 64)
+			ok = err == nil && !reflect.Zero(t).OverflowInt(i)
+		}
+		if !ok {
+			w.mismatch(doc, t, name, path)
+		}
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+		n, ok := doc.(json.Number)
+		if ok {
+			u, err := strconv.ParseUint(string(n), 10, 64)
+			ok = err == nil && !reflect.Zero(t).OverflowUint(u)
+		}
+		if !ok {
+			w.mismatch(doc, t, name, path)
+		}
+
+	case reflect.Float32, reflect.Float64:
+		n, ok := doc.(json.Number)
+		if ok {
+			f, err := strconv.ParseFloat(string(n), 64)
+			ok = err == nil && !reflect.Zero(t).OverflowFloat(f)
+		}
+		if !ok {
+			w.mismatch(doc, t, name, path)
+		}
+
+	case reflect.String:
+		if _, ok := doc.(string); !ok {
+			w.mismatch(doc, t, name, path)
+		}
+
+	case reflect.Slice, reflect.Array:
+		// A []byte is encoded as a base64 string.
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			if _, ok := doc.(string); !ok {
+				w.mismatch(doc, t, name, path)
+			}
+			return
+		}
+		elems, ok := doc.([]interface{})
+		if !ok {
+			w.mismatch(doc, t, name, path)
+			return
+		}
+		for i, e := range elems {
+			if t.Kind() == reflect.Array && i >= t.Len() {
+				break
+			}
+			v.checkJSON(e, t.Elem(), name, pointerTo(path, strconv.Itoa(i)), w)
+		}
+
+	case reflect.Map:
+		obj, ok := doc.(map[string]interface{})
+		if !ok {
+			w.mismatch(doc, t, name, path)
+			return
+		}
+		for _, k := range sortedKeys(obj) {
+			v.checkJSON(obj[k], t.Elem(), name, pointerTo(path, k), w)
+		}
+
+	case reflect.Struct:
+		obj, ok := doc.(map[string]interface{})
+		if !ok {
+			w.mismatch(doc, t, name, path)
+			return
+		}
+		fields := jsonFields(t)
+		for _, k := range sortedKeys(obj) {
+			e := obj[k]
+			fname, f, ok := lookupJSONField(fields, k)
+			if !ok {
+				w.res = append(w.res, Result{
+					Name:     k,
+					Path:     pointerTo(path, k),
+					Value:    e,
+					Kind:     DecodeRule,
+					Expr:     "unknown field",
+					Severity: SeverityError,
+					Code:     DefaultCode(t.Name(), k, DecodeRule),
+				})
+				continue
+			}
+			n, fpath := len(w.res), pointerTo(path, fname)
+			if quotedJSON(f) {
+				v.checkQuotedJSON(e, f.Type, f.Name, fpath, w)
+			} else {
+				v.checkJSON(e, f.Type, f.Name, fpath, w)
+			}
+			code, err := CodeOf(f.Tag, t.Name(), f.Name, DecodeRule)
+			if err != nil {
+				// The Validator reports bad tags.
+				code = DefaultCode(t.Name(), f.Name, DecodeRule)
+			}
+			for i := n; i < len(w.res); i++ {
+				r := &w.res[i]
+				if r.Path == fpath {
+					r.Value, r.Redacted = v.redact(w, f, r.Value)
+				}
+				if r.Code == "" {
+					r.Code = code
+				}
+			}
+			w.maskNested(n, f)
+		}
+
+	default:
+		// Channels, funcs and complex numbers can't be decoded.
+		w.mismatch(doc, t, name, path)
+	}
+}
+
+// checkQuotedJSON checks the value of a field with the ",string" option,
+// which must be null or a string holding the JSON encoding of the value.
+func (v Validator) checkQuotedJSON(doc interface{}, t reflect.Type,
+	name, path string, w *walk) {
+	str, ok := doc.(string)
+	if !ok {
+		if doc != nil {
+			w.present[path] = true
+			w.mismatch(doc, t, name, path)
+			return
+		}
+		v.checkJSON(doc, t, name, path, w)
+		return
+	}
+	dec := json.NewDecoder(strings.NewReader(str))
+	dec.UseNumber()
+	var inner interface{}
+	err := dec.Decode(&inner)
+	if err == nil {
+		if _, err = dec.Token(); err == io.EOF {
+			err = nil
+		} else {
+			err = errors.New("data after value")
+		}
+	}
+	if err != nil {
+		w.present[path] = true
+		w.mismatch(doc, t, name, path)
+		return
+	}
+	v.checkJSON(inner, t, name, path, w)
+}
+
+// quotedJSON reports whether a field has the ",string" option of its json
+// tag, and is of a type encoding/json applies it to.
+func quotedJSON(f reflect.StructField) bool {
+	return JSONConvention.Field(f).Quoted && quotable(f.Type)
+}
+
+// quotable reports whether encoding/json applies the ",string" option to
+// a type: a string, number or boolean, or a pointer to one.
+func quotable(t reflect.Type) bool {
+	if t.Name() == "" && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16,
+		reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8,
+		reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.String:
+		return true
+	}
+	return false
+}
+
+// mismatch records a value in the document that can't be decoded
+// into the Go type.
+func (w *walk) mismatch(doc interface{}, t reflect.Type, name, path string) {
+	w.res = append(w.res, Result{
+		Name:     name,
+		Path:     path,
+		Value:    doc,
+		Type:     t,
+		Kind:     DecodeRule,
+		Expr:     fmt.Sprintf("cannot decode %s into %v", jsonKind(doc), t),
+		Severity: SeverityError,
+	})
+}
+
+// jsonFields returns the decodable fields of the struct type, keyed by
+// their JSON names, including those of inlined embedded structs.  As
+// with encoding/json, fields of the outer struct take precedence.
+func jsonFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	var embedded []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		fi := JSONConvention.Field(f)
+		if fi.Skip {
+			continue
+		}
+		if fi.Inline {
+			et := f.Type
+			if et.Kind() == reflect.Ptr {
+				et = et.Elem()
+			}
+			embedded = append(embedded, et)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		fields[fi.Name] = f
+	}
+	for _, et := range embedded {
+		for k, f := range jsonFields(et) {
+			if _, ok := fields[k]; !ok {
+				fields[k] = f
+			}
+		}
+	}
+	return fields
+}
+
+// lookupJSONField finds the field for a property name, preferring an
+// exact match, but otherwise accepting a case-insensitive one, just as
+// encoding/json does.  The field's own JSON name is returned as well.
+func lookupJSONField(fields map[string]reflect.StructField,
+	key string) (string, reflect.StructField, bool) {
+	if f, ok := fields[key]; ok {
+		return key, f, true
+	}
+	for k, f := range fields {
+		if strings.EqualFold(k, key) {
+			return k, f, true
+		}
+	}
+	return "", reflect.StructField{}, false
+}
+
+// sortedKeys returns the property names of an object in order, so that
+// Results are reported in a predictable order.
+func sortedKeys(obj map[string]interface{}) []string {
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// jsonKind names the JSON type of a value in a generic document.
+func jsonKind(doc interface{}) string {
+	switch doc.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// jsonErrorPath converts the dotted field path of a type error into
+// a JSON pointer.
+func jsonErrorPath(ute *json.UnmarshalTypeError) string {
+	if ute == nil || ute.Field == "" {
+		return ""
+	}
+	var path string
+	for _, tok := range strings.Split(ute.Field, ".") {
+		path = pointerTo(path, tok)
+	}
+	return path
+}
@@ -0,0 +1,143 @@
+package tageval
+
+import (
+	"os"
+	"testing"
+)
+
+type Address struct {
+	Street string `json:"street" required:"true"`
+	Zip    string `json:"zip,omitempty" regexp:"^[0-9]{5}$"`
+}
+
+type Customer struct {
+	Name    string   `json:"name" required:"true" expr:"Name.length > 1"`
+	Age     int      `json:"age" required:"true" expr:">= 0"`
+	Active  bool     `json:"active" required:"true"`
+	Address *Address `json:"address"`
+	Tags    []string `json:"tags"`
+}
+
+func TestValidateJSON(t *testing.T) {
+	v, _ := NewValidator()
+
+	// Zero values supplied explicitly satisfy "required".
+	var c Customer
+	ok, res, err := v.ValidateJSON(
+		[]byte(`{"name": "Al", "age": 0, "active": false}`), &c)
+	if err != nil {
+		t.Fatalf("validation failed with error: %v", err)
+	}
+	if !ok {
+		PrintResults(os.Stdout, res)
+		t.Fatalf("unexpected failure result")
+	}
+	if c.Name != "Al" {
+		t.Fatalf("target was not decoded: %+v", c)
+	}
+
+	// Missing properties, unknown fields and type mismatches.
+	c = Customer{}
+	ok, res, err = v.ValidateJSON([]byte(`{"name": "A", "age": "old",
+		"adress": {}, "address": {"zip": "1234"}, "tags": ["a", 7]}`), &c)
+	if err != nil {
+		t.Fatalf("validation failed with error: %v", err)
+	}
+	if ok {
+		t.Fatalf("unexpected success result")
+	}
+	PrintResults(os.Stdout, res)
+
+	expected := []struct {
+		path string
+		kind RuleKind
+	}{
+		{"/adress", DecodeRule},
+		{"/age", DecodeRule},
+		{"/tags/1", DecodeRule},
+		{"/name", ExprRule},
+		{"/active", RequiredRule},
+		{"/address/street", RequiredRule},
+		{"/address/zip", RegexpRule},
+	}
+	if len(res) != len(expected) {
+		t.Fatalf("Expected %d results, got %d", len(expected), len(res))
+	}
+	for i, r := range res {
+		if r.Path != expected[i].path || r.Kind != expected[i].kind ||
+			r.Valid {
+			t.Fatalf("Expected result %d to be for '%+v', got '%s'", i,
+				expected[i], r.String())
+		}
+	}
+}
+
+func TestValidateJSONQuoted(t *testing.T) {
+	type Quoted struct {
+		ID    int     `json:"id,string" expr:"> 2"`
+		Rate  *string `json:"rate,string"`
+		Ready bool    `json:"ready,omitempty,string"`
+	}
+	v, _ := NewValidator()
+	var q Quoted
+	ok, res, err := v.ValidateJSON(
+		[]byte(`{"id": "5", "rate": "\"high\"", "ready": "true"}`), &q)
+	if err != nil {
+		t.Fatalf("validation failed with error: %v", err)
+	}
+	if !ok {
+		PrintResults(os.Stdout, res)
+		t.Fatalf("unexpected failure result")
+	}
+	if q.ID != 5 || *q.Rate != "high" || !q.Ready {
+		t.Fatalf("target was not decoded: %+v", q)
+	}
+
+	// Unquoted values, and quoted ones of the wrong type, are mismatches.
+	for _, doc := range []string{`{"id": 5}`, `{"id": "five"}`,
+		`{"id": "5 6"}`, `{"ready": "1"}`} {
+		q = Quoted{}
+		ok, res, err := v.ValidateJSON([]byte(doc), &q)
+		if err != nil {
+			t.Fatalf("validation of %s failed with error: %v", doc, err)
+		}
+		if ok || len(res) == 0 || res[0].Kind != DecodeRule {
+			PrintResults(os.Stdout, res)
+			t.Fatalf("unexpected result for %s", doc)
+		}
+	}
+}
+
+func TestValidateJSONErrors(t *testing.T) {
+	v, _ := NewValidator()
+	var c Customer
+	if _, _, err := v.ValidateJSON([]byte(`{"name": `), &c); err == nil {
+		t.Fatalf("did not receive expected syntax error")
+	}
+	if _, _, err := v.ValidateJSON([]byte(`{} {}`), &c); err == nil {
+		t.Fatalf("did not receive expected trailing data error")
+	}
+	if _, _, err := v.ValidateJSON([]byte(`{}`), c); err == nil {
+		t.Fatalf("did not receive expected non-pointer error")
+	}
+}
+
+func TestRequired(t *testing.T) {
+	// Outside of JSON decoding, required means non-zero.
+	v, _ := NewValidator(ShowSuccesses(true))
+	ok, res, err := v.Validate(Customer{Name: "Al", Age: 3})
+	if err != nil {
+		t.Fatalf("validation failed with error: %v", err)
+	}
+	if ok {
+		t.Fatalf("unexpected success result")
+	}
+	PrintResults(os.Stdout, res)
+	correlate(t, res, []checker{
+		{"Name", true},
+		{"Name", true},
+		{"Age", true},
+		{"Age", true},
+		{"Active", false},
+	})
+}
@@ -0,0 +1,205 @@
+package tageval
+
+import (
+	"fmt"
+	"regexp/syntax"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// jsRegexp translates a Go (RE2) regular expression into the source of
+// an equivalent JavaScript (ECMA-262) regular expression, to be used with
+// the "u" flag.  Rather than rewriting the pattern text, it is parsed and
+// the syntax tree written back out, so that Go-only syntax such as
+// (?P<name>...), \pL, [[:alpha:]], \Q...\E and inline flags all come out
+// as plain JavaScript constructs.  Anchors keep their RE2 meaning, as
+// ^ and $ match only at the beginning and end of text.
+func jsRegexp(pattern string) (string, error) {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := writeJSRegexp(&b, re); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func writeJSRegexp(b *strings.Builder, re *syntax.Regexp) error {
+	switch re.Op {
+	case syntax.OpNoMatch:
+		b.WriteString(`[^\s\S]`)
+	case syntax.OpEmptyMatch:
+		b.WriteString(`(?:)`)
+	case syntax.OpLiteral:
+		for _, r := range re.Rune {
+			if re.Flags&syntax.FoldCase != 0 && foldable(r) {
+				writeJSClass(b, foldOrbit(r))
+			} else {
+				b.WriteString(jsLiteralRune(r))
+			}
+		}
+	case syntax.OpCharClass:
+		writeJSClass(b, re.Rune)
+	case syntax.OpAnyCharNotNL:
+		b.WriteString(`[^\n]`)
+	case syntax.OpAnyChar:
+		b.WriteString(`[\s\S]`)
+	case syntax.OpBeginLine:
+		b.WriteString(`(?:^|(?<=\n))`)
+	case syntax.OpEndLine:
+		b.WriteString(`(?:$|(?=\n))`)
+	case syntax.OpBeginText:
+		b.WriteString(`^`)
+	case syntax.OpEndText:
+		b.WriteString(`$`)
+	case syntax.OpWordBoundary:
+		b.WriteString(`\b`)
+	case syntax.OpNoWordBoundary:
+		b.WriteString(`\B`)
+	case syntax.OpCapture:
+		b.WriteString("(")
+		if re.Name != "" {
+			b.WriteString("?<" + re.Name + ">")
+		}
+		if err := writeJSRegexp(b, re.Sub[0]); err != nil {
+			return err
+		}
+		b.WriteString(")")
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat:
+		if err := writeJSAtom(b, re.Sub[0]); err != nil {
+			return err
+		}
+		switch re.Op {
+		case syntax.OpStar:
+			b.WriteString("*")
+		case syntax.OpPlus:
+			b.WriteString("+")
+		case syntax.OpQuest:
+			b.WriteString("?")
+		default:
+			b.WriteString("{" + strconv.Itoa(re.Min))
+			switch {
+			case re.Max < 0:
+				b.WriteString(",")
+			case re.Max != re.Min:
+				b.WriteString("," + strconv.Itoa(re.Max))
+			}
+			b.WriteString("}")
+		}
+		if re.Flags&syntax.NonGreedy != 0 {
+			b.WriteString("?")
+		}
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			if sub.Op == syntax.OpAlternate {
+				b.WriteString("(?:")
+			}
+			if err := writeJSRegexp(b, sub); err != nil {
+				return err
+			}
+			if sub.Op == syntax.OpAlternate {
+				b.WriteString(")")
+			}
+		}
+	case syntax.OpAlternate:
+		for i, sub := range re.Sub {
+			if i > 0 {
+				b.WriteString("|")
+			}
+			if err := writeJSRegexp(b, sub); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("cannot translate regexp operation %v", re.Op)
+	}
+	return nil
+}
+
+// writeJSAtom writes a repeated expression, grouping it unless it is
+// already a single atom.
+func writeJSAtom(b *strings.Builder, re *syntax.Regexp) error {
+	atomic := false
+	switch re.Op {
+	case syntax.OpCharClass, syntax.OpAnyChar, syntax.OpAnyCharNotNL,
+		syntax.OpCapture:
+		atomic = true
+	case syntax.OpLiteral:
+		atomic = len(re.Rune) == 1
+	}
+	if atomic {
+		return writeJSRegexp(b, re)
+	}
+	b.WriteString("(?:")
+	if err := writeJSRegexp(b, re); err != nil {
+		return err
+	}
+	b.WriteString(")")
+	return nil
+}
+
+// writeJSClass writes a character class from the lo-hi rune pairs.
+func writeJSClass(b *strings.Builder, ranges []rune) {
+	switch {
+	case len(ranges) == 0:
+		b.WriteString(`[^\s\S]`)
+		return
+	case len(ranges) == 2 && ranges[0] == 0 && ranges[1] == unicode.MaxRune:
+		b.WriteString(`[\s\S]`)
+		return
+	}
+	b.WriteString("[")
+	for i := 0; i < len(ranges); i += 2 {
+		lo, hi := ranges[i], ranges[i+1]
+		b.WriteString(jsClassRune(lo))
+		if hi != lo {
+			b.WriteString("-" + jsClassRune(hi))
+		}
+	}
+	b.WriteString("]")
+}
+
+// jsLiteralRune escapes a rune outside a character class.  With the "u"
+// flag only the syntax characters may be escaped with a backslash.
+func jsLiteralRune(r rune) string {
+	switch {
+	case strings.ContainsRune(`^$\.*+?()[]{}|/`, r):
+		return `\` + string(r)
+	case r < 0x80 && unicode.IsPrint(r):
+		return string(r)
+	default:
+		return fmt.Sprintf(`\u{%X}`, r)
+	}
+}
+
+// jsClassRune escapes a rune inside a character class, where anything
+// other than a letter or digit is written as a code point escape.
+func jsClassRune(r rune) string {
+	if r < 0x80 && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+		return string(r)
+	}
+	return fmt.Sprintf(`\u{%X}`, r)
+}
+
+// foldable reports whether the rune has other case forms.
+func foldable(r rune) bool {
+	return unicode.SimpleFold(r) != r
+}
+
+// foldOrbit returns the class ranges holding all the case forms of r.
+func foldOrbit(r rune) []rune {
+	runes := []rune{r}
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		runes = append(runes, f)
+	}
+	sort.Slice(runes, func(i, j int) bool { return runes[i] < runes[j] })
+	var ranges []rune
+	for _, f := range runes {
+		ranges = append(ranges, f, f)
+	}
+	return ranges
+}
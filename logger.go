@@ -1,113 +1,78 @@
 package tageval
 
 import (
-	"fmt"
-	"io"
-	"log"
+	"context"
+	"log/slog"
+	"reflect"
+	"time"
 )
 
-// Constants for the various log levels in increasing verbosity.
-const (
-	logOff logLevel = iota
-	logErr
-	logWarn
-	logInfo
-	logTrace
-)
-
-// LogLevel is the type for logging thresholds.
-type logLevel int
+// LevelTrace is the slog level of the most detailed records, one for
+// each value traversed, below slog.LevelDebug, at which each rule's
+// result and each skipped field is logged.
+const LevelTrace = slog.LevelDebug - 4
 
-// Logger is a simple logger with configurable level filter that
-// avoids uneeded string construction.
-// Inspired in part by:
-// https://www.ardanlabs.com/blog/2013/11/using-log-package-in-go.html
-// and also the Java 8 addition of lambdas to the logging API.
+// WithLogger logs the progress of validation with the slog.Logger, which
+// is silent by default.  The records are:
 //
-// Honestly, I'm starting to see Dave Chaney's point of view that
-// loggers don't help much, except during development.  And requiring
-// a specific logger in a library seeems problematic.  Hence the simple
-// self-contained logger, that should have logging level set to "OFF",
-// unless trying to track a problem down.
-type logger struct {
-	Level    logLevel
-	traceLog *log.Logger
-	infoLog  *log.Logger
-	warnLog  *log.Logger
-	errLog   *log.Logger
+//   - "validated", at debug level, for each call, with the type, whether
+//     it was valid, the number of results and the duration;
+//   - "rule", at debug level, for each rule evaluated, with the type,
+//     field, path, kind, expr, value (masked if sensitive), valid,
+//     duration and whether a generated method supplied the result;
+//   - "skip", at debug level, for each field with rules that were not
+//     evaluated, with the type, field, path and reason, one of
+//     "private", "serialization", "omitempty", "nil" or "missing";
+//   - "traverse", at LevelTrace, for each value traversed, with its
+//     type, kind and path.
+func WithLogger(l *slog.Logger) Option {
+	return func(v *Validator) {
+		v.log = l
+	}
 }
 
-// NewLogger creates a new logger that writes to the specifed writer,
-// and uses the supplied logging level.
-func newLogger(writer io.Writer, level logLevel) *logger {
-	traceHandle := io.Discard
-	infoHandle := io.Discard
-	warningHandle := io.Discard
-	errorHandle := io.Discard
+// WithLogHandler logs the progress of validation with a slog.Logger for
+// the slog.Handler, as WithLogger does.
+func WithLogHandler(h slog.Handler) Option {
+	return WithLogger(slog.New(h))
+}
 
-	// Was I just looking for a valid use case for "fallthough"?
-	// I think this may be one of those cases where it makes sense.
-	switch level {
-	case logTrace:
-		traceHandle = writer
-		fallthrough
-	case logInfo:
-		infoHandle = writer
-		fallthrough
-	case logWarn:
-		warningHandle = writer
-		fallthrough
-	case logErr:
-		errorHandle = writer
-	case logOff:
-	}
+// discardHandler drops every record, for Validators without a logger.
+type discardHandler struct{}
 
-	traceLog := log.New(traceHandle,
-		"TRACE: ",
-		log.Ldate|log.Ltime|log.Lshortfile)
-	infoLog := log.New(infoHandle,
-		"INFO: ",
-		log.Ldate|log.Ltime|log.Lshortfile)
-	warningLog := log.New(warningHandle,
-		"WARNING: ",
-		log.Ldate|log.Ltime|log.Lshortfile)
-	errorLog := log.New(errorHandle,
-		"ERROR: ",
-		log.Ldate|log.Ltime|log.Lshortfile)
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (d discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return d }
+func (d discardHandler) WithGroup(string) slog.Handler           { return d }
 
-	return &logger{
-		level,
-		traceLog,
-		infoLog,
-		warningLog,
-		errorLog,
-	}
-}
+var discardLogger = slog.New(discardHandler{})
 
-// Trace logs messages at or above level Trace.
-func (lg *logger) trace(fmtmsg string, a ...interface{}) {
-	if lg.Level >= logTrace {
-		lg.traceLog.Output(2, fmt.Sprintf(fmtmsg, a...))
-	}
-}
-
-// Info logs messages at or above level Info.
-func (lg *logger) info(fmtmsg string, a ...interface{}) {
-	if lg.Level >= logInfo {
-		lg.infoLog.Output(2, fmt.Sprintf(fmtmsg, a...))
-	}
+// logging reports whether records of the level are wanted, to spare
+// building their attributes otherwise.
+func (v Validator) logging(level slog.Level) bool {
+	return v.log.Enabled(context.Background(), level)
 }
 
-// Warning logs messages at or above level Warning.
-func (lg *logger) warn(fmtmsg string, a ...interface{}) {
-	if lg.Level >= logWarn {
-		lg.warnLog.Output(2, fmt.Sprintf(fmtmsg, a...))
+// logSkip logs that the rules of a field were not evaluated.
+func (v Validator) logSkip(st reflect.Type, f reflect.StructField,
+	path string, reason SkipReason) {
+	if !v.logging(slog.LevelDebug) {
+		return
 	}
+	v.log.Debug("skip", slog.String("type", st.String()),
+		slog.String("field", f.Name), slog.String("path", path),
+		slog.String("reason", string(reason)))
 }
 
-// Error logs messages at or above level Error.
-func (lg *logger) err(fmtmsg string, a ...interface{}) {
-	if lg.Level >= logErr {
-		lg.errLog.Output(2, fmt.Sprintf(fmtmsg, a...))
+// logRule logs the result of evaluating a rule.
+func (v Validator) logRule(st reflect.Type, res Result, d time.Duration,
+	generated bool) {
+	if !v.logging(slog.LevelDebug) {
+		return
 	}
+	v.log.Debug("rule", slog.String("type", st.String()),
+		slog.String("field", res.Name), slog.String("path", res.Path),
+		slog.String("kind", string(res.Kind)), slog.String("expr", res.Expr),
+		slog.Any("value", res.Value), slog.Bool("valid", res.Valid),
+		slog.Duration("duration", d), slog.Bool("generated", generated))
 }
@@ -2,38 +2,89 @@ package tageval
 
 import (
 	"bytes"
+	"encoding/json"
+	"log/slog"
 	"strings"
 	"testing"
 )
 
-func TestLogger(t *testing.T) {
-	type levelTest struct {
-		level    logLevel
-		expected []string
-	}
+type Logged struct {
+	Name string `json:"name" expr:"Name.length > 2"`
+	Note string `json:"note,omitempty" expr:"Note.length < 5"`
+	Gone string `json:"-" expr:"Gone != ''"`
+	Ptr  *int   `json:"ptr" expr:"Ptr > 0"`
+	ID   string `json:"id" required:"true" regexp:"^[0-9]+$"`
+	hid  string `expr:"hid != ''"`
+}
 
-	var tests []levelTest
-	tests = append(tests, levelTest{logOff, []string{"", "", "", ""}})
-	tests = append(tests, levelTest{logTrace,
-		[]string{"hello", "hello", "hello", "hello"}})
-	tests = append(tests, levelTest{logInfo,
-		[]string{"", "hello", "hello", "hello"}})
-	tests = append(tests, levelTest{logWarn,
-		[]string{"", "", "hello", "hello"}})
-	tests = append(tests, levelTest{logErr,
-		[]string{"", "", "", "hello"}})
+func TestLogger(t *testing.T) {
+	for _, level := range []slog.Level{LevelTrace, slog.LevelDebug,
+		slog.LevelInfo} {
+		var b bytes.Buffer
+		v, _ := NewValidator(WithLogHandler(slog.NewJSONHandler(&b,
+			&slog.HandlerOptions{Level: level})))
+		if _, _, err := v.Validate(Logged{Name: "Al"}); err != nil {
+			t.Fatalf("validation failed with error: %v", err)
+		}
 
-	lf := []func(*logger, string, ...interface{}){
-		(*logger).trace, (*logger).info, (*logger).warn, (*logger).err}
+		var got []string
+		traversed := 0
+		for _, line := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+			if line == "" {
+				continue
+			}
+			var rec map[string]interface{}
+			if err := json.Unmarshal([]byte(line), &rec); err != nil {
+				t.Fatalf("bad record %s: %v", line, err)
+			}
+			switch rec["msg"] {
+			case "traverse":
+				traversed++
+				if _, ok := rec["path"]; !ok {
+					t.Fatalf("traverse record lacks path: %s", line)
+				}
+			case "rule":
+				if rec["type"] != "tageval.Logged" {
+					t.Fatalf("rule record lacks type: %s", line)
+				}
+				if _, ok := rec["duration"]; !ok {
+					t.Fatalf("rule record lacks duration: %s", line)
+				}
+				got = append(got, "rule "+rec["path"].(string)+" "+
+					rec["kind"].(string))
+			case "skip":
+				got = append(got, "skip "+rec["field"].(string)+" "+
+					rec["reason"].(string))
+			case "validated":
+				if rec["valid"] != false || rec["results"] != 2.0 {
+					t.Fatalf("unexpected validated record: %s", line)
+				}
+				got = append(got, "validated")
+			default:
+				t.Fatalf("unexpected record: %s", line)
+			}
+		}
 
-	for _, ltest := range tests {
-		for i, fn := range lf {
-			bb := new(bytes.Buffer)
-			log := newLogger(bb, ltest.level)
-			fn(log, "hello")
-			if !strings.HasSuffix(strings.TrimSpace(bb.String()), ltest.expected[i]) {
-				t.Fatalf("log produced wrong string: '%s'", bb.String())
+		var expected []string
+		if level <= slog.LevelDebug {
+			expected = []string{
+				"rule /name expr",
+				"skip Note omitempty",
+				"skip Gone serialization",
+				"skip Ptr nil",
+				"rule /id required",
+				"skip ID missing",
+				"skip hid private",
+				"validated",
 			}
 		}
+		if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+			t.Fatalf("level %v: expected records:\n%s\ngot:\n%s", level,
+				strings.Join(expected, "\n"), strings.Join(got, "\n"))
+		}
+		if (traversed > 0) != (level == LevelTrace) {
+			t.Fatalf("level %v: unexpected %d traverse records", level,
+				traversed)
+		}
 	}
 }
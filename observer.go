@@ -0,0 +1,275 @@
+package tageval
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// A SkipReason says why the rules of a field were not evaluated.
+type SkipReason string
+
+// The reasons for skipping a field's rules.
+const (
+	// SkipPrivate is for an unexported field, with serialization rules
+	// in effect.
+	SkipPrivate SkipReason = "private"
+	// SkipSerialization is for a field the Convention leaves out, such
+	// as one tagged `json:"-"`.
+	SkipSerialization SkipReason = "serialization"
+	// SkipOmitEmpty is for an omitempty field holding the zero value.
+	SkipOmitEmpty SkipReason = "omitempty"
+	// SkipNil is for a nil pointer or interface.
+	SkipNil SkipReason = "nil"
+	// SkipMissing is for the other rules of a required field that is
+	// missing.
+	SkipMissing SkipReason = "missing"
+)
+
+// An Observer is told of the progress of validation, for collecting
+// metrics such as how often each rule fails and how long it takes.
+// Observers are registered with WithObserver, and called synchronously
+// from the goroutine validating, so they should be quick, and must be
+// safe for concurrent use if the Validator or its copies are used
+// concurrently.
+type Observer interface {
+	// OnStructEnter is called for each struct value reached, with the
+	// JSON pointer to it.
+	OnStructEnter(t reflect.Type, path string)
+
+	// OnRuleEvaluated is called for each rule evaluated, successful or
+	// not, whether or not successes are shown, with the time it took.
+	OnRuleEvaluated(res Result, d time.Duration)
+
+	// OnSkip is called for each field with rules that were not
+	// evaluated.
+	OnSkip(st reflect.Type, f reflect.StructField, path string,
+		reason SkipReason)
+
+	// OnError is called with the error that stopped a validation.
+	OnError(err error)
+}
+
+// WithObserver registers an Observer, in addition to any registered
+// already.
+func WithObserver(o Observer) Option {
+	return func(v *Validator) {
+		v.observers = append(v.observers[:len(v.observers):len(v.observers)],
+			o)
+	}
+}
+
+// skipped logs and reports to the observers that the rules of a field
+// were not evaluated.
+func (v Validator) skipped(st reflect.Type, f reflect.StructField,
+	path string, reason SkipReason) {
+	v.logSkip(st, f, path, reason)
+	for _, o := range v.observers {
+		o.OnSkip(st, f, path, reason)
+	}
+}
+
+// evaluated logs and reports to the observers the result of a rule,
+// which is generated if it was supplied by a generated method.
+func (v Validator) evaluated(st reflect.Type, res Result, d time.Duration,
+	generated bool) {
+	v.logRule(st, res, d, generated)
+	for _, o := range v.observers {
+		o.OnRuleEvaluated(res, d)
+	}
+}
+
+// defaultBuckets are the upper bounds of the latency histogram buckets of
+// Stats, unless others are given to NewStats.
+var defaultBuckets = []time.Duration{
+	time.Microsecond,
+	10 * time.Microsecond,
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+}
+
+// RuleStats holds the statistics of a rule, identified by its Code.
+type RuleStats struct {
+	Code      string
+	Kind      RuleKind
+	Evaluated int
+	Failed    int
+	Total     time.Duration
+
+	// Buckets holds the number of evaluations that took no longer than
+	// the corresponding bound of the Stats' Buckets, with one more for
+	// the rest.
+	Buckets []int
+}
+
+// Mean returns the mean time taken to evaluate the rule.
+func (rs RuleStats) Mean() time.Duration {
+	if rs.Evaluated == 0 {
+		return 0
+	}
+	return rs.Total / time.Duration(rs.Evaluated)
+}
+
+// Stats is an Observer that counts the evaluations and failures of each
+// rule, and keeps a histogram of the time they take, along with the
+// number of skips by reason and of errors.  It is safe for concurrent
+// use.
+type Stats struct {
+	mu      sync.Mutex
+	buckets []time.Duration
+	rules   map[string]*RuleStats
+	skips   map[SkipReason]int
+	errors  int
+}
+
+// NewStats returns an empty Stats, whose latency histogram buckets have
+// the given upper bounds, in any order, with a last bucket for the
+// evaluations that took longer.  With no bounds, they run by factors of
+// ten from a microsecond to 100 milliseconds.
+func NewStats(buckets ...time.Duration) *Stats {
+	if len(buckets) == 0 {
+		buckets = defaultBuckets
+	}
+	buckets = append([]time.Duration(nil), buckets...)
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i] < buckets[j]
+	})
+	return &Stats{
+		buckets: buckets,
+		rules:   make(map[string]*RuleStats),
+		skips:   make(map[SkipReason]int),
+	}
+}
+
+// Buckets returns the upper bounds of the latency histogram buckets.
+func (s *Stats) Buckets() []time.Duration {
+	return append([]time.Duration(nil), s.buckets...)
+}
+
+// OnStructEnter implements Observer.
+func (s *Stats) OnStructEnter(t reflect.Type, path string) {}
+
+// OnRuleEvaluated implements Observer.
+func (s *Stats) OnRuleEvaluated(res Result, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rs := s.rules[res.Code]
+	if rs == nil {
+		rs = &RuleStats{Code: res.Code, Kind: res.Kind,
+			Buckets: make([]int, len(s.buckets)+1)}
+		s.rules[res.Code] = rs
+	}
+	rs.Evaluated++
+	if !res.Valid {
+		rs.Failed++
+	}
+	rs.Total += d
+	i := sort.Search(len(s.buckets), func(i int) bool {
+		return d <= s.buckets[i]
+	})
+	rs.Buckets[i]++
+}
+
+// OnSkip implements Observer.
+func (s *Stats) OnSkip(st reflect.Type, f reflect.StructField, path string,
+	reason SkipReason) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.skips[reason]++
+}
+
+// OnError implements Observer.
+func (s *Stats) OnError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errors++
+}
+
+// Rules returns the statistics of each rule evaluated, ordered by Code.
+func (s *Stats) Rules() []RuleStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rules := make([]RuleStats, 0, len(s.rules))
+	for _, rs := range s.rules {
+		c := *rs
+		c.Buckets = append([]int(nil), rs.Buckets...)
+		rules = append(rules, c)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].Code < rules[j].Code
+	})
+	return rules
+}
+
+// Skips returns the number of fields skipped for each reason.
+func (s *Stats) Skips() map[SkipReason]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	skips := make(map[SkipReason]int, len(s.skips))
+	for k, n := range s.skips {
+		skips[k] = n
+	}
+	return skips
+}
+
+// Errors returns the number of validations stopped by an error.
+func (s *Stats) Errors() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.errors
+}
+
+// Reset clears the statistics.
+func (s *Stats) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules = make(map[string]*RuleStats)
+	s.skips = make(map[SkipReason]int)
+	s.errors = 0
+}
+
+// Write writes a table of the statistics of each rule, with its latency
+// histogram, followed by the skips and errors.
+func (s *Stats) Write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprint(tw, "rule\tevaluated\tfailed\tmean\t")
+	for _, b := range s.buckets {
+		fmt.Fprintf(tw, "<=%v\t", b)
+	}
+	if n := len(s.buckets); n > 0 {
+		fmt.Fprintf(tw, ">%v", s.buckets[n-1])
+	}
+	fmt.Fprintln(tw)
+	for _, rs := range s.Rules() {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%v", rs.Code, rs.Evaluated, rs.Failed,
+			rs.Mean())
+		for _, n := range rs.Buckets {
+			fmt.Fprintf(tw, "\t%d", n)
+		}
+		fmt.Fprintln(tw)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	skips := s.Skips()
+	reasons := make([]string, 0, len(skips))
+	for r := range skips {
+		reasons = append(reasons, string(r))
+	}
+	sort.Strings(reasons)
+	for _, r := range reasons {
+		if _, err := fmt.Fprintf(w, "skipped (%s): %d\n", r,
+			skips[SkipReason(r)]); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "errors: %d\n", s.Errors())
+	return err
+}
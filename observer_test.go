@@ -0,0 +1,128 @@
+package tageval
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recorder is an Observer noting each call.
+type recorder struct {
+	calls []string
+}
+
+func (r *recorder) OnStructEnter(t reflect.Type, path string) {
+	r.calls = append(r.calls, "enter "+t.Name()+" "+path)
+}
+
+func (r *recorder) OnRuleEvaluated(res Result, d time.Duration) {
+	r.calls = append(r.calls, "rule "+res.Code+" "+res.Path)
+}
+
+func (r *recorder) OnSkip(st reflect.Type, f reflect.StructField,
+	path string, reason SkipReason) {
+	r.calls = append(r.calls, "skip "+f.Name+" "+string(reason))
+}
+
+func (r *recorder) OnError(err error) {
+	r.calls = append(r.calls, "error")
+}
+
+func TestObserver(t *testing.T) {
+	type Outer struct {
+		In  Logged `json:"in"`
+		Bad int    `json:"bad" expr:"Bad > 0" severity:"fatal"`
+	}
+	rec := &recorder{}
+	stats := NewStats()
+	v, _ := NewValidator(WithObserver(rec), WithObserver(stats))
+	for i := 0; i < 2; i++ {
+		if _, _, err := v.Validate(Logged{Name: "Alice", ID: "7"}); err != nil {
+			t.Fatalf("validation failed with error: %v", err)
+		}
+	}
+	expected := []string{
+		"enter Logged ",
+		"rule Logged.Name.expr /name",
+		"skip Note omitempty",
+		"skip Gone serialization",
+		"skip Ptr nil",
+		"rule Logged.ID.required /id",
+		"rule Logged.ID.regexp /id",
+		"skip hid private",
+	}
+	if got := strings.Join(rec.calls, "\n"); got != strings.Join(
+		append(expected, expected...), "\n") {
+		t.Fatalf("unexpected calls:\n%s", got)
+	}
+
+	rec.calls = nil
+	if _, _, err := v.Validate(Outer{In: Logged{Name: "Al"}}); err == nil {
+		t.Fatalf("did not get expected error")
+	}
+	if len(rec.calls) < 2 || rec.calls[0] != "enter Outer " ||
+		rec.calls[1] != "enter Logged /in" ||
+		rec.calls[len(rec.calls)-1] != "error" {
+		t.Fatalf("unexpected calls:\n%s", strings.Join(rec.calls, "\n"))
+	}
+
+	rules := stats.Rules()
+	if len(rules) != 3 || rules[0].Code != "Logged.ID.regexp" ||
+		rules[0].Evaluated != 2 || rules[0].Failed != 0 ||
+		rules[1].Code != "Logged.ID.required" || rules[1].Evaluated != 3 ||
+		rules[1].Failed != 1 || rules[2].Code != "Logged.Name.expr" ||
+		rules[2].Evaluated != 3 || rules[2].Failed != 1 {
+		t.Fatalf("unexpected rule stats: %+v", rules)
+	}
+	n := 0
+	for _, b := range rules[2].Buckets {
+		n += b
+	}
+	if n != 3 || rules[2].Mean() <= 0 {
+		t.Fatalf("unexpected histogram: %+v", rules[2])
+	}
+	if skips := stats.Skips(); skips[SkipPrivate] != 3 ||
+		skips[SkipMissing] != 1 || stats.Errors() != 1 {
+		t.Fatalf("unexpected skips %v or errors %d", skips, stats.Errors())
+	}
+
+	var b bytes.Buffer
+	if err := stats.Write(&b); err != nil {
+		t.Fatalf("writing stats failed with error: %v", err)
+	}
+	os.Stdout.Write(b.Bytes())
+	for _, want := range []string{"rule", "<=1µs", ">100ms",
+		"Logged.Name.expr", "skipped (missing): 1", "errors: 1"} {
+		if !strings.Contains(b.String(), want) {
+			t.Fatalf("stats lack %s:\n%s", want, b.String())
+		}
+	}
+
+	stats.Reset()
+	if len(stats.Rules()) != 0 {
+		t.Fatalf("stats not reset")
+	}
+}
+
+func TestStatsBuckets(t *testing.T) {
+	s := NewStats(time.Hour, time.Nanosecond)
+	b := s.Buckets()
+	if len(b) != 2 || b[0] != time.Nanosecond || b[1] != time.Hour {
+		t.Fatalf("unexpected buckets %v", b)
+	}
+
+	// The bounds are the Stats' own.
+	b[0] = time.Minute
+	s.OnRuleEvaluated(Result{Code: "x"}, time.Minute)
+	s.OnRuleEvaluated(Result{Code: "x"}, 2*time.Hour)
+	if rs := s.Rules(); len(rs) != 1 ||
+		!reflect.DeepEqual(rs[0].Buckets, []int{0, 1, 1}) {
+		t.Fatalf("unexpected rule stats %+v", rs)
+	}
+	if n := len(NewStats().Buckets()); n != 6 {
+		t.Fatalf("got %d default buckets, expected 6", n)
+	}
+}
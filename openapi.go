@@ -0,0 +1,41 @@
+package tageval
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Components is the "components" object of an OpenAPI 3.1 document,
+// holding the schemas of the types it was generated for.  It is meant to
+// be marshaled with encoding/json, and merged into a document under the
+// "components" key.
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+// OpenAPIComponents generates the OpenAPI 3.1 "components.schemas" entries
+// for the named struct types, along with those of any named structs they
+// refer to.  The schemas are those generated by Schema (OpenAPI 3.1 uses
+// JSON Schema draft 2020-12), with references of the form
+// "#/components/schemas/Name", and with field descriptions taken from the
+// `doc` tag.  As the original rules are the single source of truth, every
+// expression and regexp is also carried in the "x-tageval-expr" and
+// "x-tageval-regexp" vendor extensions, even where it was mapped to a
+// keyword such as "pattern" or "minimum".
+func (v Validator) OpenAPIComponents(types ...reflect.Type) (*Components, error) {
+	sg := &schemaGen{v: v, rules: v.currentRules(),
+		refPrefix: "#/components/schemas/", keepRules: true,
+		defs: make(map[string]*Schema), names: make(map[reflect.Type]string)}
+	for _, t := range types {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct || t.Name() == "" {
+			return nil, fmt.Errorf("%v is not a named struct type", t)
+		}
+		if _, err := sg.schema(t); err != nil {
+			return nil, err
+		}
+	}
+	return &Components{Schemas: sg.defs}, nil
+}
@@ -0,0 +1,43 @@
+package tageval
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOpenAPIComponents(t *testing.T) {
+	type Item struct {
+		SKU   string  `json:"sku" doc:"Stock keeping unit" regexp:"^\\pL+$"`
+		Price float64 `json:"price,omitempty" doc:"Unit price" expr:">= 0.01"`
+		Note  string  `json:"note,omitempty" expr:"Note.indexOf('!') < 0"`
+		Alt   *Line   `json:"alt,omitempty"`
+	}
+
+	v, _ := NewValidator()
+	c, err := v.OpenAPIComponents(reflect.TypeOf(Item{}))
+	if err != nil {
+		t.Fatalf("component generation failed with error: %v", err)
+	}
+
+	expected := `{"schemas":{` +
+		`"Item":{"type":"object","properties":{` +
+		`"alt":{"anyOf":[{"$ref":"#/components/schemas/Line"},{"type":"null"}]},` +
+		`"note":{"type":"string","x-tageval-expr":"Note.indexOf('!') < 0"},` +
+		`"price":{"description":"Unit price","type":"number","minimum":0.01,` +
+		`"x-tageval-expr":"Price >= 0.01"},` +
+		`"sku":{"description":"Stock keeping unit","type":"string",` +
+		`"pattern":"^[A-Za-z\\u{AA}`
+	if got := marshalSchema(t, c); !strings.HasPrefix(got, expected) {
+		t.Fatalf("unexpected components:\n%s\nexpected prefix:\n%s", got, expected)
+	}
+	line := c.Schemas["Line"]
+	if line == nil || line.Properties["sku"].Regexp != "^[A-Z]{3}-[0-9]+$" ||
+		line.Properties["qty"].Expr != "Quantity > 0" {
+		t.Fatalf("unexpected Line schema: %s", marshalSchema(t, line))
+	}
+
+	if _, err := v.OpenAPIComponents(reflect.TypeOf(0)); err == nil {
+		t.Fatalf("did not receive expected error")
+	}
+}
@@ -0,0 +1,64 @@
+package tageval
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// The registry of named types, for tools that validate data without
+// knowing its Go type at compile time.
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]reflect.Type)
+)
+
+// RegisterType makes a type available by name, for tools such as the
+// filecheck package that validate records named on the command line.
+// The exemplar is any value of the type, or a pointer to one, as in
+//
+//	tageval.RegisterType("Order", Order{})
+//
+// It is meant to be called from init functions, and panics if the name
+// is empty or already registered, or the exemplar is nil.
+func RegisterType(name string, exemplar interface{}) {
+	if name == "" {
+		panic("tageval: RegisterType with empty name")
+	}
+	t := reflect.TypeOf(exemplar)
+	if t == nil {
+		panic("tageval: RegisterType of nil exemplar for " + name)
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if prev, dup := registry[name]; dup {
+		panic(fmt.Sprintf("tageval: RegisterType called twice for %s (%v, %v)",
+			name, prev, t))
+	}
+	registry[name] = t
+}
+
+// RegisteredType returns the type registered under the name.
+func RegisteredType(name string) (reflect.Type, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	t, ok := registry[name]
+	return t, ok
+}
+
+// RegisteredTypes returns the sorted names of the registered types.
+func RegisteredTypes() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
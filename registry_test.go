@@ -0,0 +1,36 @@
+package tageval
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRegisterType(t *testing.T) {
+	type Registered struct {
+		Name string `expr:"Name.length > 0"`
+	}
+	RegisterType("tageval.Registered", &Registered{})
+
+	rt, ok := RegisteredType("tageval.Registered")
+	if !ok || rt != reflect.TypeOf(Registered{}) {
+		t.Fatalf("unexpected registered type: %v", rt)
+	}
+	found := false
+	for _, name := range RegisteredTypes() {
+		found = found || name == "tageval.Registered"
+	}
+	if !found {
+		t.Fatalf("registered type not listed")
+	}
+
+	for _, name := range []string{"", "tageval.Registered"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("expected panic registering %q", name)
+				}
+			}()
+			RegisterType(name, Registered{})
+		}()
+	}
+}
@@ -0,0 +1,398 @@
+package tageval
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// A ResultSet is the outcome of validating one item, for reporting.
+// Source says what the item was, such as "orders.json:12", and may be
+// empty when there is only the one item.  Err is set if the item could
+// not be validated at all, such as when its JSON was malformed.
+type ResultSet struct {
+	Source  string
+	Results []Result
+	Err     error
+}
+
+// Valid reports whether the item passed validation, which it does despite
+// failed rules of a Severity other than SeverityError.
+func (rs ResultSet) Valid() bool {
+	if rs.Err != nil {
+		return false
+	}
+	for _, r := range rs.Results {
+		if r.Fails() {
+			return false
+		}
+	}
+	return true
+}
+
+// A Reporter writes validation results in some format, for people or
+// for tools such as CI dashboards and log pipelines.
+type Reporter interface {
+	Report(w io.Writer, sets ...ResultSet) error
+}
+
+// The built-in Reporters.
+//
+// TextReporter writes the format of PrintResults, heading each set with
+// "Results:", or "Results for <source>:".  Sets with a source but no
+// results or error are left out, so that only failures appear when many
+// items were validated without ShowSuccesses.
+//
+// JSONReporter writes an array with an object for each set, holding its
+// source, validity, error and results.  NDJSONReporter writes a line for
+// each result or error, with its source, and CSVReporter a row, after a
+// header row.
+//
+// JUnitReporter writes JUnit XML, with a test suite for each set and a
+// test case for each result, or a single passing case for a set with no
+// results.  TAPReporter writes the Test Anything Protocol, version 13,
+// with a test for each result, or for each set with no results, along
+// with the details of failures in YAML.  Failed rules that are only
+// warnings or information are passing cases with the failure as output
+// in JUnit, and TODO tests in TAP, so that neither fails a build.
+var (
+	TextReporter   Reporter = textReporter{}
+	JSONReporter   Reporter = jsonReporter{}
+	NDJSONReporter Reporter = ndjsonReporter{}
+	CSVReporter    Reporter = csvReporter{}
+	JUnitReporter  Reporter = junitReporter{}
+	TAPReporter    Reporter = tapReporter{}
+)
+
+// LookupReporter returns the built-in Reporter for a format name:
+// "text", "json", "ndjson", "csv", "junit" or "tap".
+func LookupReporter(format string) (Reporter, bool) {
+	r, ok := map[string]Reporter{
+		"text":   TextReporter,
+		"json":   JSONReporter,
+		"ndjson": NDJSONReporter,
+		"csv":    CSVReporter,
+		"junit":  JUnitReporter,
+		"tap":    TAPReporter,
+	}[format]
+	return r, ok
+}
+
+// resultJSON is the JSON form of a Result.
+type resultJSON struct {
+	Name     string          `json:"name"`
+	Path     string          `json:"path,omitempty"`
+	Value    json.RawMessage `json:"value"`
+	Type     string          `json:"type,omitempty"`
+	Kind     RuleKind        `json:"kind,omitempty"`
+	Expr     string          `json:"expr,omitempty"`
+	Valid    bool            `json:"valid"`
+	Redacted bool            `json:"redacted,omitempty"`
+	Severity Severity        `json:"severity,omitempty"`
+	Code     string          `json:"code,omitempty"`
+	Message  string          `json:"message,omitempty"`
+}
+
+// MarshalJSON encodes a Result as a JSON object, with the Type as its Go
+// name.  The Value is encoded as JSON if it can be, and otherwise as the
+// string it formats to with fmt, so that channels, functions and the like
+// never cause an error.  A Redacted value is flagged as "redacted".
+// The JSON and NDJSON Reporters leave characters such as "<" and ">" in
+// expressions unescaped.
+func (res Result) MarshalJSON() ([]byte, error) {
+	return json.Marshal(res.toJSON())
+}
+
+func (res Result) toJSON() resultJSON {
+	rj := resultJSON{Name: res.Name, Path: res.Path, Kind: res.Kind,
+		Expr: res.Expr, Valid: res.Valid, Value: jsonValue(res.Value),
+		Redacted: res.Redacted, Severity: res.Severity, Code: res.Code,
+		Message: res.Message}
+	if res.Type != nil {
+		rj.Type = res.Type.String()
+	}
+	return rj
+}
+
+// jsonValue encodes a value for a report, falling back on its fmt form.
+func jsonValue(val interface{}) json.RawMessage {
+	if data, err := json.Marshal(val); err == nil {
+		return data
+	}
+	data, _ := json.Marshal(fmt.Sprintf("%+v", val))
+	return data
+}
+
+type textReporter struct{}
+
+func (textReporter) Report(w io.Writer, sets ...ResultSet) error {
+	bw := bufio.NewWriter(w)
+	for _, rs := range sets {
+		if rs.Source == "" {
+			fmt.Fprintln(bw, "Results:")
+		} else if len(rs.Results) > 0 || rs.Err != nil {
+			fmt.Fprintf(bw, "Results for %s:\n", rs.Source)
+		}
+		if rs.Err != nil {
+			fmt.Fprintf(bw, "error: %v\n", rs.Err)
+		}
+		for i := range rs.Results {
+			fmt.Fprintln(bw, rs.Results[i].String())
+		}
+	}
+	return bw.Flush()
+}
+
+type setJSON struct {
+	Source  string   `json:"source,omitempty"`
+	Valid   bool     `json:"valid"`
+	Error   string   `json:"error,omitempty"`
+	Results []Result `json:"results"`
+}
+
+type jsonReporter struct{}
+
+func (jsonReporter) Report(w io.Writer, sets ...ResultSet) error {
+	out := make([]setJSON, 0, len(sets))
+	for _, rs := range sets {
+		sj := setJSON{Source: rs.Source, Valid: rs.Valid(),
+			Results: rs.Results}
+		if sj.Results == nil {
+			sj.Results = []Result{}
+		}
+		if rs.Err != nil {
+			sj.Error = rs.Err.Error()
+		}
+		out = append(out, sj)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+type ndjsonReporter struct{}
+
+func (ndjsonReporter) Report(w io.Writer, sets ...ResultSet) error {
+	type line struct {
+		Source string `json:"source,omitempty"`
+		Error  string `json:"error,omitempty"`
+		*resultJSON
+	}
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	enc.SetEscapeHTML(false)
+	for _, rs := range sets {
+		if rs.Err != nil {
+			err := enc.Encode(line{Source: rs.Source, Error: rs.Err.Error()})
+			if err != nil {
+				return err
+			}
+		}
+		for _, r := range rs.Results {
+			rj := r.toJSON()
+			if err := enc.Encode(line{Source: rs.Source,
+				resultJSON: &rj}); err != nil {
+				return err
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+type csvReporter struct{}
+
+func (csvReporter) Report(w io.Writer, sets ...ResultSet) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"source", "name", "path", "kind", "code", "expr",
+		"value", "type", "valid", "severity", "message", "error"})
+	for _, rs := range sets {
+		if rs.Err != nil {
+			cw.Write([]string{rs.Source, "", "", "", "", "", "", "",
+				"false", string(SeverityError), "", rs.Err.Error()})
+		}
+		for _, r := range rs.Results {
+			var typ string
+			if r.Type != nil {
+				typ = r.Type.String()
+			}
+			cw.Write([]string{rs.Source, r.Name, r.Path, string(r.Kind),
+				r.Code, r.Expr, fmt.Sprintf("%+v", r.Value), typ,
+				strconv.FormatBool(r.Valid), string(r.Severity), r.Message,
+				""})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// The JUnit XML elements, as understood by the common CI servers.
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Errors   int          `xml:"errors,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitReporter struct{}
+
+func (junitReporter) Report(w io.Writer, sets ...ResultSet) error {
+	var out junitSuites
+	for _, rs := range sets {
+		s := junitSuite{Name: rs.Source}
+		if s.Name == "" {
+			s.Name = "tageval"
+		}
+		if rs.Err != nil {
+			s.Cases = append(s.Cases, junitCase{ClassName: s.Name,
+				Name: "validate", Error: &junitProblem{
+					Message: rs.Err.Error(), Type: "error"}})
+			s.Errors++
+		}
+		for i := range rs.Results {
+			r := &rs.Results[i]
+			c := junitCase{ClassName: s.Name, Name: caseName(r)}
+			if r.Fails() {
+				c.Failure = &junitProblem{Message: r.Message,
+					Type: string(r.Kind), Text: fmt.Sprintf("%+v", r.Value)}
+				if c.Failure.Message == "" {
+					c.Failure.Message = r.String()
+				}
+				s.Failures++
+			} else if !r.Valid {
+				c.SystemOut = r.String()
+			}
+			s.Cases = append(s.Cases, c)
+		}
+		if len(s.Cases) == 0 {
+			s.Cases = append(s.Cases, junitCase{ClassName: s.Name,
+				Name: "valid"})
+		}
+		s.Tests = len(s.Cases)
+		out.Tests += s.Tests
+		out.Failures += s.Failures
+		out.Errors += s.Errors
+		out.Suites = append(out.Suites, s)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// caseName names the test for a result by its path, or its name if it
+// has none, and its kind.
+func caseName(r *Result) string {
+	name := r.Path
+	if name == "" {
+		name = r.Name
+	}
+	return name + " " + string(r.Kind)
+}
+
+type tapReporter struct{}
+
+func (tapReporter) Report(w io.Writer, sets ...ResultSet) error {
+	n := 0
+	for _, rs := range sets {
+		n += len(rs.Results)
+		if rs.Err != nil || len(rs.Results) == 0 {
+			n++
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "TAP version 13\n1..%d\n", n)
+	i := 0
+	test := func(ok bool, desc, todo string, diag [][2]string) {
+		i++
+		status := "ok"
+		if !ok {
+			status = "not ok"
+		}
+		fmt.Fprintf(bw, "%s %d - %s", status, i, tapEscape(desc))
+		if todo != "" {
+			fmt.Fprintf(bw, " # TODO %s", todo)
+		}
+		fmt.Fprintln(bw)
+		if len(diag) > 0 {
+			fmt.Fprintln(bw, "  ---")
+			for _, kv := range diag {
+				fmt.Fprintf(bw, "  %s: %s\n", kv[0], strconv.Quote(kv[1]))
+			}
+			fmt.Fprintln(bw, "  ...")
+		}
+	}
+	for _, rs := range sets {
+		prefix := ""
+		if rs.Source != "" {
+			prefix = rs.Source + " "
+		}
+		if rs.Err != nil {
+			test(false, prefix+"validate", "", [][2]string{
+				{"message", rs.Err.Error()}})
+		} else if len(rs.Results) == 0 {
+			test(true, prefix+"valid", "", nil)
+		}
+		for j := range rs.Results {
+			r := &rs.Results[j]
+			var diag [][2]string
+			var todo string
+			if !r.Valid {
+				diag = [][2]string{{"message", r.String()},
+					{"expr", r.Expr}, {"value", fmt.Sprintf("%+v", r.Value)}}
+				if r.Code != "" {
+					diag = append(diag, [2]string{"code", r.Code})
+				}
+			}
+			if !r.Valid && !r.Fails() {
+				todo = string(r.Severity)
+				diag = append(diag, [2]string{"severity", todo})
+			}
+			test(r.Valid, prefix+caseName(r), todo, diag)
+		}
+	}
+	return bw.Flush()
+}
+
+// tapEscape escapes the "#" that would start a directive, and keeps a
+// description on one line.
+func tapEscape(s string) string {
+	s = strings.ReplaceAll(s, "#", `\#`)
+	return strings.ReplaceAll(s, "\n", " ")
+}
@@ -0,0 +1,167 @@
+package tageval
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func reportSets() []ResultSet {
+	return []ResultSet{
+		{Source: "a.json:1", Results: []Result{
+			{Name: "Total", Path: "/total", Value: 3, Type: reflect.TypeOf(0),
+				Kind: ExprRule, Expr: "Total > 5", Valid: false,
+				Severity: SeverityError, Code: "Order.Total.expr"},
+			{Name: "ID", Path: "/id", Value: "7", Type: reflect.TypeOf(""),
+				Kind: RegexpRule, Expr: "^[0-9]+$", Valid: true},
+		}},
+		{Source: "a.json:2", Results: []Result{
+			{Name: "Note", Path: "/note", Value: "", Type: reflect.TypeOf(""),
+				Kind: ExprRule, Expr: "Note.length > 0", Valid: false,
+				Severity: SeverityWarning},
+		}},
+		{Source: "a.json:3", Err: errors.New("unexpected EOF")},
+	}
+}
+
+func TestResultJSON(t *testing.T) {
+	for _, tc := range []struct {
+		res      Result
+		expected string
+	}{
+		{Result{Name: "A", Path: "/a", Value: 1.5, Type: reflect.TypeOf(1.5),
+			Kind: ExprRule, Expr: "A > 2"},
+			`{"name":"A","path":"/a","value":1.5,"type":"float64","kind":"expr","expr":"A \u003e 2","valid":false}`},
+		{Result{Name: "N"}, `{"name":"N","value":null,"valid":false}`},
+	} {
+		data, err := json.Marshal(tc.res)
+		if err != nil {
+			t.Fatalf("marshaling failed with error: %v", err)
+		}
+		if string(data) != tc.expected {
+			t.Fatalf("expected %s, got %s", tc.expected, data)
+		}
+	}
+
+	// A value that can't be encoded is given in its fmt form.
+	data, err := json.Marshal(Result{Name: "C", Value: make(chan int)})
+	if err != nil {
+		t.Fatalf("marshaling failed with error: %v", err)
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatalf("unmarshaling failed with error: %v", err)
+	}
+	if s, ok := m["value"].(string); !ok || !strings.HasPrefix(s, "0x") {
+		t.Fatalf("unexpected channel value: %s", data)
+	}
+}
+
+func TestReporters(t *testing.T) {
+	var b bytes.Buffer
+	TextReporter.Report(&b, reportSets()...)
+	expected := `Results for a.json:1:
+'Total' (type: int) item: '3', expr: 'Total > 5' : failed
+'ID' (type: string) item: '7', expr: '^[0-9]+$' : ok
+Results for a.json:3:
+error: unexpected EOF
+`
+	expected = strings.Replace(expected, "Results for a.json:3", `Results for a.json:2:
+'Note' (type: string) item: '', expr: 'Note.length > 0' : failed (warning)
+Results for a.json:3`, 1)
+	if b.String() != expected {
+		t.Fatalf("text: expected:\n%s\ngot:\n%s", expected, b.String())
+	}
+
+	b.Reset()
+	JSONReporter.Report(&b, reportSets()...)
+	var sets []struct {
+		Source  string
+		Valid   bool
+		Error   string
+		Results []json.RawMessage
+	}
+	if err := json.Unmarshal(b.Bytes(), &sets); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if len(sets) != 3 || sets[0].Valid || !sets[1].Valid ||
+		len(sets[0].Results) != 2 || len(sets[1].Results) != 1 ||
+		sets[2].Error != "unexpected EOF" {
+		t.Fatalf("json: unexpected report:\n%s", b.String())
+	}
+
+	b.Reset()
+	NDJSONReporter.Report(&b, reportSets()...)
+	expected = `{"source":"a.json:1","name":"Total","path":"/total","value":3,"type":"int","kind":"expr","expr":"Total > 5","valid":false,"severity":"error","code":"Order.Total.expr"}
+{"source":"a.json:1","name":"ID","path":"/id","value":"7","type":"string","kind":"regexp","expr":"^[0-9]+$","valid":true}
+{"source":"a.json:2","name":"Note","path":"/note","value":"","type":"string","kind":"expr","expr":"Note.length > 0","valid":false,"severity":"warning"}
+{"source":"a.json:3","error":"unexpected EOF"}
+`
+	if b.String() != expected {
+		t.Fatalf("ndjson: expected:\n%s\ngot:\n%s", expected, b.String())
+	}
+
+	b.Reset()
+	CSVReporter.Report(&b, reportSets()...)
+	expected = `source,name,path,kind,code,expr,value,type,valid,severity,message,error
+a.json:1,Total,/total,expr,Order.Total.expr,Total > 5,3,int,false,error,,
+a.json:1,ID,/id,regexp,,^[0-9]+$,7,string,true,,,
+a.json:2,Note,/note,expr,,Note.length > 0,,string,false,warning,,
+a.json:3,,,,,,,,false,error,,unexpected EOF
+`
+	if b.String() != expected {
+		t.Fatalf("csv: expected:\n%s\ngot:\n%s", expected, b.String())
+	}
+
+	b.Reset()
+	JUnitReporter.Report(&b, reportSets()...)
+	var suites junitSuites
+	if err := xml.Unmarshal(b.Bytes(), &suites); err != nil {
+		t.Fatalf("junit: %v", err)
+	}
+	if suites.Tests != 4 || suites.Failures != 1 || suites.Errors != 1 ||
+		len(suites.Suites) != 3 || suites.Suites[0].Cases[0].Name !=
+		"/total expr" || suites.Suites[1].Cases[0].Failure != nil ||
+		suites.Suites[1].Cases[0].SystemOut == "" {
+		t.Fatalf("junit: unexpected report:\n%s", b.String())
+	}
+
+	b.Reset()
+	TAPReporter.Report(&b, reportSets()...)
+	expected = `TAP version 13
+1..4
+not ok 1 - a.json:1 /total expr
+  ---
+  message: "'Total' (type: int) item: '3', expr: 'Total > 5' : failed"
+  expr: "Total > 5"
+  value: "3"
+  code: "Order.Total.expr"
+  ...
+ok 2 - a.json:1 /id regexp
+not ok 3 - a.json:2 /note expr # TODO warning
+  ---
+  message: "'Note' (type: string) item: '', expr: 'Note.length > 0' : failed (warning)"
+  expr: "Note.length > 0"
+  value: ""
+  severity: "warning"
+  ...
+not ok 4 - a.json:3 validate
+  ---
+  message: "unexpected EOF"
+  ...
+`
+	if b.String() != expected {
+		t.Fatalf("tap: expected:\n%s\ngot:\n%s", expected, b.String())
+	}
+
+	for _, format := range []string{"text", "json", "ndjson", "csv", "junit",
+		"tap"} {
+		if _, ok := LookupReporter(format); !ok {
+			t.Fatalf("no reporter for %s", format)
+		}
+	}
+}
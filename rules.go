@@ -0,0 +1,251 @@
+package tageval
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/robertkrimen/otto/parser"
+)
+
+// ruleKey identifies a rule of a field of a struct type.
+type ruleKey struct {
+	t     reflect.Type
+	field string
+	kind  RuleKind
+}
+
+// externalRules holds the rules added to a Validator, in place of or in
+// addition to those in tags.  The RuleSet is replaced, never changed, so
+// that it may be read without locking, and swapped while validations are
+// under way.  It is shared by copies of the Validator.
+//
+// Anything storing set holds mu while it does, and SetRules and addRules
+// both do, so that the read-modify-write of addRules can't undo a
+// concurrent SetRules, nor two calls of addRules lose one another's
+// rules.  Readers only Load set, and never take mu.
+type externalRules struct {
+	mu  sync.Mutex
+	set atomic.Pointer[RuleSet]
+}
+
+// AddRule adds a rule for a field of a struct type, for types whose tags
+// can't be changed, such as those of generated or third-party packages.
+// The kind is ExprRule, RegexpRule, RequiredRule, TransitionRule or
+// ImmutableRule, and expr is what the tag of that kind would hold, so that
+//
+//	v.AddRule(reflect.TypeOf(pb.Order{}), "Total", tageval.ExprRule, "> 0")
+//
+// acts as if the Total field were tagged `expr:"> 0"`.  An added rule
+// takes precedence over the field's tag of the same kind, replacing it,
+// and an empty expr removes it.  The field's tags of other kinds still
+// apply, as do its severity and code tags.  The rule applies to every
+// copy of the Validator.
+func (v Validator) AddRule(t reflect.Type, field string, kind RuleKind,
+	expr string) error {
+	rs := NewRuleSet()
+	if err := rs.Add(t, field, kind, expr); err != nil {
+		return err
+	}
+	return v.addRules(rs)
+}
+
+// LoadRules adds the rules in the files in fsys matching the pattern, as
+// read by LoadRuleSet.  The rules of all of the files are checked before
+// any are added.
+func (v Validator) LoadRules(fsys fs.FS, pattern string) error {
+	rs, err := LoadRuleSet(fsys, pattern)
+	if err != nil {
+		return err
+	}
+	return v.addRules(rs)
+}
+
+// SetRules replaces all of the rules added to the Validator and its
+// copies with those of the RuleSet, or removes them if it is nil.  The
+// swap is atomic: a validation under way uses either the old rules or
+// the new ones throughout, as each takes the rules in effect when it
+// starts.  The RuleSet is copied, so it may be changed afterwards without
+// affecting the Validator.
+func (v Validator) SetRules(rs *RuleSet) error {
+	if v.rules == nil {
+		return fmt.Errorf("validator was not made by NewValidator")
+	}
+	v.rules.mu.Lock()
+	defer v.rules.mu.Unlock()
+	if rs == nil {
+		v.rules.set.Store(nil)
+	} else {
+		v.rules.set.Store(rs.merge(nil))
+	}
+	return nil
+}
+
+// Rules returns a copy of the rules added to the Validator.
+func (v Validator) Rules() *RuleSet {
+	return v.currentRules().merge(nil)
+}
+
+// addRules adds checked rules, replacing the RuleSet so that validations
+// under way see either all or none of them.
+func (v Validator) addRules(add *RuleSet) error {
+	if v.rules == nil {
+		return fmt.Errorf("validator was not made by NewValidator")
+	}
+	v.rules.mu.Lock()
+	defer v.rules.mu.Unlock()
+	v.rules.set.Store(v.rules.set.Load().merge(add))
+	return nil
+}
+
+// currentRules returns the rules added to the Validator, or nil if there
+// are none.  A validation, or the generation of a schema or module, takes
+// them once and uses them throughout, so that it never sees some of the
+// rules of one RuleSet and some of another.
+func (v Validator) currentRules() *RuleSet {
+	if v.rules == nil {
+		return nil
+	}
+	return v.rules.set.Load()
+}
+
+// rule returns the rule of the kind for a field of the struct type st,
+// as given by rs, the rules added to the Validator, or else as given by
+// the field's tag.
+func (v Validator) rule(rs *RuleSet, st reflect.Type, f reflect.StructField,
+	kind RuleKind) string {
+	if rs != nil {
+		if r, ok := rs.rules[ruleKey{st, f.Name, kind}]; ok {
+			return r
+		}
+	}
+	switch kind {
+	case ExprRule:
+		return f.Tag.Get(v.exprTag)
+	case RegexpRule:
+		return f.Tag.Get(v.regexpTag)
+	case RequiredRule:
+		return f.Tag.Get(RequiredTag)
+	case TransitionRule:
+		return f.Tag.Get(TransitionTag)
+	case ImmutableRule:
+		return f.Tag.Get(ImmutableTag)
+	}
+	return ""
+}
+
+// checkRule checks that a rule names a field of a struct type, and that
+// its expr is valid for its kind.
+func checkRule(t reflect.Type, field string, kind RuleKind,
+	expr string) (ruleKey, error) {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return ruleKey{}, fmt.Errorf("cannot add rule to %v, not a struct", t)
+	}
+	found := false
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Name == field {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return ruleKey{}, fmt.Errorf("%v has no field %s", t, field)
+	}
+	var err error
+	switch {
+	case !containsKind(ruleKinds, kind):
+		return ruleKey{}, fmt.Errorf("unknown rule kind %q", kind)
+	case expr == "":
+	case kind == ExprRule, kind == TransitionRule:
+		_, err = parser.ParseFile(nil, "", ExpandShortcut(field, expr), 0)
+	case kind == RegexpRule:
+		_, err = regexp.Compile(expr)
+	case kind == RequiredRule, kind == ImmutableRule:
+		_, err = strconv.ParseBool(expr)
+	}
+	if err != nil {
+		return ruleKey{}, fmt.Errorf("invalid %s rule for %v.%s: %v", kind,
+			t, field, err)
+	}
+	return ruleKey{t, field, kind}, nil
+}
+
+// ruleSpec is a rule as given in a JSON rule file.
+type ruleSpec struct {
+	Type  string   `json:"type"`
+	Field string   `json:"field"`
+	Kind  RuleKind `json:"kind"`
+	Expr  string   `json:"expr"`
+}
+
+// loadRuleFile checks the rules of a file and adds them to the map.
+func loadRuleFile(fsys fs.FS, name string, rules map[ruleKey]string) error {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return err
+	}
+	var specs []ruleSpec
+	if path.Ext(name) == ".json" {
+		if err := json.Unmarshal(data, &specs); err != nil {
+			return err
+		}
+	} else if specs, err = parseRules(data); err != nil {
+		return err
+	}
+	for i, spec := range specs {
+		t, ok := RegisteredType(spec.Type)
+		if !ok {
+			return fmt.Errorf("rule %d: unknown type %q", i+1, spec.Type)
+		}
+		key, err := checkRule(t, spec.Field, spec.Kind, spec.Expr)
+		if err != nil {
+			return fmt.Errorf("rule %d: %v", i+1, err)
+		}
+		rules[key] = spec.Expr
+	}
+	return nil
+}
+
+// parseRules parses the rules of a text rule file.
+func parseRules(data []byte) ([]ruleSpec, error) {
+	var specs []ruleSpec
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		target, rest := cutSpace(line)
+		kind, expr := cutSpace(rest)
+		dot := strings.LastIndex(target, ".")
+		if dot <= 0 || kind == "" {
+			return nil, fmt.Errorf("line %d: expected Type.Field kind expr",
+				n)
+		}
+		specs = append(specs, ruleSpec{Type: target[:dot],
+			Field: target[dot+1:], Kind: RuleKind(kind),
+			Expr: expr})
+	}
+	return specs, sc.Err()
+}
+
+// cutSpace cuts s around its first run of white space.
+func cutSpace(s string) (string, string) {
+	i := strings.IndexAny(s, " \t")
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], strings.TrimSpace(s[i:])
+}
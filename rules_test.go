@@ -0,0 +1,145 @@
+package tageval
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// Vendor stands in for a type whose tags can't be changed.
+type Vendor struct {
+	Total int    `json:"total" expr:"> 100"`
+	ID    string `json:"id" regexp:"^x"`
+	Note  string `json:"note"`
+	Email string `json:"email"`
+}
+
+func init() {
+	RegisterType("RuleVendor", Vendor{})
+}
+
+func TestAddRule(t *testing.T) {
+	vt := reflect.TypeOf(Vendor{})
+	v, _ := NewValidator(ShowSuccesses(true))
+	for _, r := range []struct {
+		field string
+		kind  RuleKind
+		expr  string
+	}{
+		{"Total", ExprRule, "> 0"},
+		{"ID", RegexpRule, ""},
+		{"Note", RequiredRule, "true"},
+		{"Note", ExprRule, "Note.length < 3"},
+	} {
+		if err := v.AddRule(vt, r.field, r.kind, r.expr); err != nil {
+			t.Fatalf("adding rule failed with error: %v", err)
+		}
+	}
+
+	// The rules apply to copies, and to pointers to the type.
+	for _, cv := range []*Validator{v, v.Copy()} {
+		ok, res, err := cv.Validate(&Vendor{Total: 5, ID: "y"})
+		if err != nil {
+			t.Fatalf("validation failed with error: %v", err)
+		}
+		PrintResults(os.Stdout, res)
+		if ok {
+			t.Fatalf("unexpected success")
+		}
+		correlate(t, res, []checker{{"Total", true}, {"Note", false}})
+		if res[0].Expr != "Total > 0" || res[1].Kind != RequiredRule ||
+			res[1].Code != "Vendor.Note.required" {
+			t.Fatalf("unexpected results %v", res)
+		}
+	}
+
+	var b bytes.Buffer
+	if err := v.WriteJSModule(&b, vt); err != nil {
+		t.Fatalf("module generation failed with error: %v", err)
+	}
+	if !strings.Contains(b.String(), "return Total > 0;") ||
+		strings.Contains(b.String(), "100") {
+		t.Fatalf("module does not use added rules:\n%s", b.String())
+	}
+	s, err := v.Schema(vt)
+	if err != nil {
+		t.Fatalf("schema generation failed with error: %v", err)
+	}
+	data, _ := json.Marshal(s)
+	if bytes.Contains(data, []byte(`"^x"`)) ||
+		!bytes.Contains(data, []byte(`"exclusiveMinimum":0`)) {
+		t.Fatalf("schema does not use added rules: %s", data)
+	}
+
+	for _, r := range []struct {
+		t     reflect.Type
+		field string
+		kind  RuleKind
+		expr  string
+	}{
+		{reflect.TypeOf(0), "Total", ExprRule, "> 0"},
+		{vt, "Missing", ExprRule, "> 0"},
+		{vt, "Total", "length", "> 0"},
+		{vt, "Total", "length", ""},
+		{vt, "Total", ExprRule, "Total >"},
+		{vt, "ID", RegexpRule, "(x"},
+		{vt, "Note", RequiredRule, "maybe"},
+	} {
+		if err := v.AddRule(r.t, r.field, r.kind, r.expr); err == nil {
+			t.Fatalf("did not get expected error for %+v", r)
+		}
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	fsys := fstest.MapFS{
+		"rules/vendor.json": {Data: []byte(`[
+			{"type": "RuleVendor", "field": "Total", "kind": "expr", "expr": "< 10"},
+			{"type": "RuleVendor", "field": "Email", "kind": "required", "expr": "true"}
+		]`)},
+		"rules/vendor.rules": {Data: []byte(`# Vendor rules
+RuleVendor.ID	regexp ^[0-9]+$
+
+RuleVendor.Email regexp  ^[^@ ]+@[^@ ]+$
+`)},
+	}
+	v, _ := NewValidator()
+	if err := v.LoadRules(fsys, "rules/*"); err != nil {
+		t.Fatalf("loading rules failed with error: %v", err)
+	}
+	_, res, err := v.Validate(Vendor{Total: 50, ID: "x1", Email: "a b"})
+	if err != nil {
+		t.Fatalf("validation failed with error: %v", err)
+	}
+	PrintResults(os.Stdout, res)
+	correlate(t, res, []checker{{"Total", false}, {"ID", false},
+		{"Email", false}})
+
+	for name, data := range map[string]string{
+		"unknown.json": `[{"type": "Nope", "field": "ID", "kind": "expr", "expr": "true"}]`,
+		"bad.json":     `{"type": "RuleVendor"}`,
+		"field.rules":  "RuleVendor.Nope expr true\n",
+		"line.rules":   "RuleVendor\n",
+		"expr.rules":   "RuleVendor.Total expr > > 0\n",
+	} {
+		// A bad file keeps the good ones from being added.
+		v, _ := NewValidator()
+		err := v.LoadRules(fstest.MapFS{
+			"a.rules": {Data: []byte("RuleVendor.Total expr < 10\n")},
+			name:      {Data: []byte(data)},
+		}, "*")
+		if err == nil {
+			t.Fatalf("did not get expected error for %s", name)
+		}
+		if ok, _, _ := v.Validate(Vendor{Total: 200, ID: "x"}); !ok {
+			t.Fatalf("rules added despite error in %s", name)
+		}
+	}
+	if err := v.LoadRules(fsys, "none/*"); err == nil {
+		t.Fatalf("did not get expected error for no files")
+	}
+}
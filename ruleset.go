@@ -0,0 +1,210 @@
+package tageval
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// A RuleSet is a set of rules for fields of struct types, in place of or
+// in addition to those in tags, to be installed on a Validator with
+// SetRules.  Each rule is checked as it is added, so a RuleSet holds only
+// rules that compile, and installing it can't leave a Validator with a
+// broken rule.  The zero value is an empty RuleSet.
+type RuleSet struct {
+	rules map[ruleKey]string
+}
+
+// NewRuleSet returns an empty RuleSet.
+func NewRuleSet() *RuleSet {
+	return &RuleSet{rules: make(map[ruleKey]string)}
+}
+
+// LoadRuleSet reads a RuleSet from the files in fsys matching the pattern
+// understood by fs.Glob, naming their types as registered with
+// RegisterType.  An error is returned if no file matches or any rule is
+// invalid.
+//
+// A ".json" file holds an array of rules, each an object with type,
+// field, kind and expr properties:
+//
+//	[{"type": "Order", "field": "Total", "kind": "expr", "expr": "> 0"}]
+//
+// Any other file holds a rule per line, with the type and field, the kind
+// and the expr separated by white space:
+//
+//	# Orders must be for something.
+//	Order.Total expr > 0
+//	Order.ID regexp ^[0-9]+$
+//	Order.Note expr
+//
+// Blank lines and those starting with "#" are ignored, and a rule
+// without an expr removes the tag rule of its kind, as with AddRule.
+func LoadRuleSet(fsys fs.FS, pattern string) (*RuleSet, error) {
+	names, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no rule files match %s", pattern)
+	}
+	rs := NewRuleSet()
+	for _, name := range names {
+		if err := loadRuleFile(fsys, name, rs.rules); err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return rs, nil
+}
+
+// Add adds a rule for a field of a struct type, as Validator.AddRule
+// does, replacing any rule of the same kind for the field already in the
+// RuleSet.
+func (rs *RuleSet) Add(t reflect.Type, field string, kind RuleKind,
+	expr string) error {
+	key, err := checkRule(t, field, kind, expr)
+	if err != nil {
+		return err
+	}
+	if rs.rules == nil {
+		rs.rules = make(map[ruleKey]string)
+	}
+	rs.rules[key] = expr
+	return nil
+}
+
+// Len returns the number of rules in the RuleSet.
+func (rs *RuleSet) Len() int {
+	if rs == nil {
+		return 0
+	}
+	return len(rs.rules)
+}
+
+// merge returns a new RuleSet with the rules of rs, which may be nil, and
+// those of add, which take precedence.
+func (rs *RuleSet) merge(add *RuleSet) *RuleSet {
+	m := NewRuleSet()
+	if rs != nil {
+		for k, r := range rs.rules {
+			m.rules[k] = r
+		}
+	}
+	if add != nil {
+		for k, r := range add.rules {
+			m.rules[k] = r
+		}
+	}
+	return m
+}
+
+// A RuleWatcher reloads the rules of a Validator from files whenever they
+// change, so that rules may be changed without restarting.  It polls the
+// modification times and sizes of the files, rather than relying on the
+// operating system to report changes, which also serves file systems
+// such as fstest.MapFS.  A RuleSet read from changed files replaces all
+// of the rules added to the Validator, but only if every rule in it is
+// valid; otherwise the rules in effect are kept, and the error reported.
+type RuleWatcher struct {
+	v       *Validator
+	fsys    fs.FS
+	pattern string
+
+	mu     sync.Mutex
+	stamps map[string]fileStamp
+}
+
+// fileStamp is what is compared to tell that a file has changed.
+type fileStamp struct {
+	mod  time.Time
+	size int64
+}
+
+// NewRuleWatcher returns a RuleWatcher loading the rules of the Validator
+// from the files in fsys matching the pattern, as LoadRuleSet does.  The
+// files are not read until Check or Run is called.
+func NewRuleWatcher(v *Validator, fsys fs.FS, pattern string) *RuleWatcher {
+	return &RuleWatcher{v: v, fsys: fsys, pattern: pattern}
+}
+
+// Check loads the rules if the files matching the pattern have changed
+// since they were last loaded, or if one has been added or removed, and
+// reports whether they were.  The first call always loads them.  If the
+// files can't be read or hold an invalid rule, the Validator's rules are
+// left as they were, and the error returned; the files are tried again
+// at the next call, even if unchanged since.
+func (w *RuleWatcher) Check() (bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	stamps, err := w.stat()
+	if err != nil {
+		return false, err
+	}
+	if w.stamps != nil && sameStamps(stamps, w.stamps) {
+		return false, nil
+	}
+	w.stamps = nil
+	rs, err := LoadRuleSet(w.fsys, w.pattern)
+	if err != nil {
+		return false, err
+	}
+	if err := w.v.SetRules(rs); err != nil {
+		return false, err
+	}
+	w.stamps = stamps
+	return true, nil
+}
+
+// Run calls Check at each interval until the context is done, passing
+// each error to onError, which may be nil.  The first check is made at
+// once.  Run returns the context's error.
+func (w *RuleWatcher) Run(ctx context.Context, interval time.Duration,
+	onError func(error)) error {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		if _, err := w.Check(); err != nil && onError != nil {
+			onError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// stat returns the stamps of the files matching the pattern.
+func (w *RuleWatcher) stat() (map[string]fileStamp, error) {
+	names, err := fs.Glob(w.fsys, w.pattern)
+	if err != nil {
+		return nil, err
+	}
+	stamps := make(map[string]fileStamp, len(names))
+	for _, name := range names {
+		fi, err := fs.Stat(w.fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		stamps[name] = fileStamp{fi.ModTime(), fi.Size()}
+	}
+	return stamps, nil
+}
+
+// sameStamps reports whether two sets of stamps are the same.
+func sameStamps(a, b map[string]fileStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, sa := range a {
+		sb, ok := b[name]
+		if !ok || !sa.mod.Equal(sb.mod) || sa.size != sb.size {
+			return false
+		}
+	}
+	return true
+}
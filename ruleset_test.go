@@ -0,0 +1,154 @@
+package tageval
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestSetRules(t *testing.T) {
+	vt := reflect.TypeOf(Vendor{})
+	v, _ := NewValidator()
+	if err := v.AddRule(vt, "ID", RegexpRule, "^y"); err != nil {
+		t.Fatalf("adding rule failed with error: %v", err)
+	}
+
+	rs := NewRuleSet()
+	if err := rs.Add(vt, "Total", ExprRule, "< 10"); err != nil {
+		t.Fatalf("adding rule failed with error: %v", err)
+	}
+	if err := rs.Add(vt, "Total", ExprRule, "Total >"); err == nil {
+		t.Fatalf("did not get expected error for bad rule")
+	}
+	cv := v.Copy()
+	if err := v.SetRules(rs); err != nil {
+		t.Fatalf("setting rules failed with error: %v", err)
+	}
+
+	// The RuleSet replaces the added rules, for copies too, and is copied.
+	rs.Add(vt, "Note", RequiredRule, "true")
+	_, res, err := cv.Validate(Vendor{Total: 50, ID: "x"})
+	if err != nil {
+		t.Fatalf("validation failed with error: %v", err)
+	}
+	PrintResults(os.Stdout, res)
+	correlate(t, res, []checker{{"Total", false}})
+	if n := v.Rules().Len(); n != 1 {
+		t.Fatalf("got %d rules, expected 1", n)
+	}
+
+	if err := v.SetRules(nil); err != nil {
+		t.Fatalf("clearing rules failed with error: %v", err)
+	}
+	if ok, _, _ := v.Validate(Vendor{Total: 500, ID: "x"}); !ok {
+		t.Fatalf("rules not cleared")
+	}
+}
+
+func TestRuleWatcher(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"rules/vendor.rules": {Data: []byte("RuleVendor.Total expr < 10\n"),
+			ModTime: start},
+	}
+	v, _ := NewValidator()
+	w := NewRuleWatcher(v, fsys, "rules/*.rules")
+	check := func(want bool, valid bool) {
+		t.Helper()
+		changed, err := w.Check()
+		if err != nil {
+			t.Fatalf("check failed with error: %v", err)
+		}
+		if changed != want {
+			t.Fatalf("got changed %v, expected %v", changed, want)
+		}
+		if ok, _, _ := v.Validate(Vendor{Total: 50, ID: "x"}); ok != valid {
+			t.Fatalf("got valid %v, expected %v", ok, valid)
+		}
+	}
+	check(true, false)
+	check(false, false)
+
+	// A changed file is reloaded, as is an added one.
+	fsys["rules/vendor.rules"] = &fstest.MapFile{
+		Data: []byte("RuleVendor.Total expr < 100\n"), ModTime: start.Add(time.Second)}
+	check(true, true)
+	fsys["rules/id.rules"] = &fstest.MapFile{
+		Data: []byte("RuleVendor.ID regexp ^y\n"), ModTime: start}
+	check(true, false)
+	delete(fsys, "rules/id.rules")
+	check(true, true)
+
+	// A bad rule keeps the rules in effect, until it is fixed.
+	fsys["rules/vendor.rules"] = &fstest.MapFile{
+		Data: []byte("RuleVendor.Total expr < < 10\n"), ModTime: start.Add(2 * time.Second)}
+	for i := 0; i < 2; i++ {
+		if _, err := w.Check(); err == nil {
+			t.Fatalf("did not get expected error for bad rule")
+		}
+		if ok, _, _ := v.Validate(Vendor{Total: 50, ID: "x"}); !ok {
+			t.Fatalf("rules replaced despite error")
+		}
+	}
+	fsys["rules/vendor.rules"] = &fstest.MapFile{
+		Data: []byte("RuleVendor.Total expr < 10\n"), ModTime: start.Add(2 * time.Second)}
+	check(true, false)
+
+	// Run checks at once, and stops with the context.
+	v, _ = NewValidator()
+	w = NewRuleWatcher(v, fsys, "none/*")
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		errs <- w.Run(ctx, time.Hour, func(err error) {
+			cancel()
+		})
+	}()
+	if err := <-errs; err != context.Canceled {
+		t.Fatalf("run returned %v, expected %v", err, context.Canceled)
+	}
+}
+
+type Pair struct {
+	A int `expr:"A == 1"`
+	B int `expr:"B == 1"`
+}
+
+// swapper sets the rules of a Validator after the first rule of a
+// validation is evaluated.
+type swapper struct {
+	Stats
+	v  *Validator
+	rs *RuleSet
+}
+
+func (s *swapper) OnRuleEvaluated(res Result, d time.Duration) {
+	if s.rs != nil {
+		s.v.SetRules(s.rs)
+		s.rs = nil
+	}
+}
+
+func TestSetRulesDuringValidation(t *testing.T) {
+	pt := reflect.TypeOf(Pair{})
+	rs := NewRuleSet()
+	rs.Add(pt, "A", ExprRule, "A == 2")
+	rs.Add(pt, "B", ExprRule, "B == 2")
+	sw := &swapper{rs: rs}
+	v, _ := NewValidator(ShowSuccesses(true), WithObserver(sw))
+	sw.v = v
+
+	// The validation under way keeps the rules it started with.
+	_, res, err := v.Validate(Pair{1, 1})
+	if err != nil {
+		t.Fatalf("validation failed with error: %v", err)
+	}
+	PrintResults(os.Stdout, res)
+	correlate(t, res, []checker{{"A", true}, {"B", true}})
+
+	_, res, _ = v.Validate(Pair{1, 1})
+	correlate(t, res, []checker{{"A", false}, {"B", false}})
+}
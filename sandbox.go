@@ -0,0 +1,130 @@
+package tageval
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/robertkrimen/otto"
+)
+
+// ErrTimeout is returned by validation when an expression runs for longer
+// than the Timeout of a Validator's SandboxConfig.
+var ErrTimeout = errors.New("expression timed out")
+
+// defaultStackDepth is the call depth allowed by a SandboxConfig that
+// doesn't give one.  Expressions seldom need more than a few calls.
+const defaultStackDepth = 100
+
+// SandboxConfig sets the limits on expressions run by a Validator with
+// the Sandbox option.
+type SandboxConfig struct {
+	// MaxStackDepth caps the depth of JavaScript function calls, so that
+	// runaway recursion fails with a RangeError.  Zero means 100.
+	MaxStackDepth int
+
+	// Timeout, if not zero, caps the time each expression may run, after
+	// which validation fails with ErrTimeout.
+	Timeout time.Duration
+}
+
+// Sandbox runs expressions in a locked down JavaScript engine, so that
+// expr rules may be written by people who are not trusted with the
+// process, such as the administrators of tenants in a hosted service.
+// In the sandbox:
+//
+//   - the builtin objects, such as Object, Array and Math, and their
+//     prototypes are frozen, and the globals holding them can't be
+//     reassigned, so that no expression can change how another behaves;
+//   - console is removed, unless WithConsole gives it somewhere to go;
+//   - every expression starts from the same globals, so that variables
+//     set by one, and the values of the fields bound for earlier ones,
+//     are gone by the next, while variables set with Bind remain;
+//   - the call depth, and optionally the run time, are capped.
+//
+// Starting afresh costs a copy of the engine for each expression, so
+// that validation is noticeably slower than without the sandbox.
+func Sandbox(cfg SandboxConfig) Option {
+	return func(v *Validator) {
+		v.sandbox = &cfg
+	}
+}
+
+// sandboxScript freezes the builtins of the global object it is run in,
+// and makes the globals themselves read only.
+const sandboxScript = `(function(global) {
+	var names = Object.getOwnPropertyNames(global);
+	for (var i = 0; i < names.length; i++) {
+		// The console is left to be replaced in copies.
+		if (names[i] === "console") {
+			continue;
+		}
+		var desc = Object.getOwnPropertyDescriptor(global, names[i]);
+		var val = desc.value;
+		if (val !== null &&
+			(typeof val === "object" || typeof val === "function")) {
+			Object.freeze(val);
+			if (val.prototype) {
+				Object.freeze(val.prototype);
+			}
+		}
+		if ("value" in desc) {
+			desc.writable = false;
+		}
+		desc.configurable = false;
+		Object.defineProperty(global, names[i], desc);
+	}
+})(this);`
+
+// sandbox locks down the engine, keeping it as the base that each
+// expression runs in a fresh copy of.
+func (e *evaluator) sandbox(cfg SandboxConfig) error {
+	depth := cfg.MaxStackDepth
+	if depth <= 0 {
+		depth = defaultStackDepth
+	}
+	if e.console == nil {
+		if _, err := e.vm.Run("delete console"); err != nil {
+			return fmt.Errorf("setting up sandbox: %v", err)
+		}
+	}
+	if _, err := e.vm.Run(sandboxScript); err != nil {
+		return fmt.Errorf("setting up sandbox: %v", err)
+	}
+	e.base = e.vm
+	e.base.SetStackDepthLimit(depth)
+	e.timeout = cfg.Timeout
+	e.vm = e.base.Copy()
+	return nil
+}
+
+// reset starts a sandboxed engine afresh from its base.
+func (e *evaluator) reset() {
+	if e.base != nil {
+		e.vm = e.base.Copy()
+	}
+}
+
+// runLimited runs a script, interrupting it if it runs past the timeout.
+func (e *evaluator) runLimited(script *otto.Script) (res otto.Value,
+	err error) {
+	vm := e.vm
+	interrupt := make(chan func(), 1)
+	vm.Interrupt = interrupt
+	timer := time.AfterFunc(e.timeout, func() {
+		interrupt <- func() {
+			panic(ErrTimeout)
+		}
+	})
+	defer func() {
+		timer.Stop()
+		vm.Interrupt = nil
+		if r := recover(); r != nil {
+			if r != ErrTimeout {
+				panic(r)
+			}
+			err = ErrTimeout
+		}
+	}()
+	return vm.Run(script)
+}
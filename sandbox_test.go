@@ -0,0 +1,103 @@
+package tageval
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+type Tenant struct {
+	A int `expr:"leaked = A; var hoisted = A; A > 0"`
+	B int `expr:"typeof leaked == 'undefined' && typeof hoisted == 'undefined'"`
+	C int `expr:"typeof A == 'undefined' && typeof console == 'undefined'"`
+	D int `expr:"Array.prototype.evil = 1; Math.abs = Math.floor; JSON = null; Math.abs(D) == 3"`
+	E int `expr:"[].evil === undefined && JSON !== null && E < limit"`
+}
+
+func TestSandbox(t *testing.T) {
+	// Without the sandbox, expressions see what earlier ones left behind.
+	v, _ := NewValidator()
+	v.Bind("limit", 5)
+	ok, res, err := v.Validate(Tenant{A: 1, D: -3, E: 2})
+	if err != nil {
+		t.Fatalf("validation failed with error: %v", err)
+	}
+	PrintResults(os.Stdout, res)
+	if ok {
+		t.Fatalf("unexpected success without sandbox")
+	}
+
+	v, _ = NewValidator(Sandbox(SandboxConfig{}), ShowSuccesses(true))
+	if err := v.Bind("limit", 5); err != nil {
+		t.Fatalf("binding failed with error: %v", err)
+	}
+	for _, cv := range []*Validator{v, v.Copy()} {
+		for i := 0; i < 2; i++ {
+			ok, res, err = cv.Validate(Tenant{A: 1, D: -3, E: 2})
+			if err != nil {
+				t.Fatalf("validation failed with error: %v", err)
+			}
+			PrintResults(os.Stdout, res)
+			if !ok {
+				t.Fatalf("unexpected failure in sandbox")
+			}
+			correlate(t, res, []checker{
+				{"A", true},
+				{"B", true},
+				{"C", true},
+				{"D", true},
+				{"E", true},
+			})
+		}
+	}
+
+	// Ordinary expressions run as before.
+	b1 := byte(3)
+	ti := TalkingInt(7)
+	ms1 := &MyStruct{A: 1, B: time.Now(), C: "hello",
+		D: []Another{{"Joe", "Plano, TX"}},
+		E: &b1, G: Another{"bingo", "Oshkosh, WI"},
+		H: &ti, I: map[string]int{"green": 12, "blue": 93}, j: "Pete",
+		L: "uoiea", M: 3.14, N: time.Now().Add(2 * time.Second),
+		P: []int{1, 2, 3, 4}}
+	_, res, err = v.Validate(ms1)
+	if err != nil {
+		t.Fatalf("validation failed with error: %v", err)
+	}
+	if len(res) != 16 {
+		t.Fatalf("expected 16 results, got %d", len(res))
+	}
+}
+
+func TestSandboxLimits(t *testing.T) {
+	type Deep struct {
+		N int `expr:"(function f(n) { return f(n + 1) })(N)"`
+	}
+	v, _ := NewValidator(Sandbox(SandboxConfig{MaxStackDepth: 10}))
+	_, _, err := v.Validate(Deep{})
+	if err == nil || !strings.Contains(err.Error(), "RangeError") {
+		t.Fatalf("did not get expected stack error: %v", err)
+	}
+
+	type Loop struct {
+		N int `expr:"while (true) {}"`
+		M int `expr:"M == 0"`
+	}
+	v, _ = NewValidator(Sandbox(SandboxConfig{
+		Timeout: 50 * time.Millisecond}))
+	_, _, err = v.Validate(Loop{})
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("did not get expected timeout error: %v", err)
+	}
+
+	// The engine is still usable after a timeout.
+	type Quick struct {
+		M int `expr:"M == 0"`
+	}
+	ok, _, err := v.Validate(Quick{})
+	if err != nil || !ok {
+		t.Fatalf("unexpected result after timeout: %t, %v", ok, err)
+	}
+}
@@ -0,0 +1,416 @@
+package tageval
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SchemaDraft is the JSON Schema dialect produced by Validator.Schema.
+const SchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// A Schema is a JSON Schema document, or one of its subschemas, covering
+// the subset of draft 2020-12 needed to describe tagged Go types.  It is
+// meant to be marshaled with encoding/json.
+//
+// Validation rules that can't be expressed in JSON Schema are recorded in
+// the "x-tageval-expr" and "x-tageval-regexp" extensions.
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	ContentEncoding      string             `json:"contentEncoding,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     *float64           `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     *float64           `json:"exclusiveMaximum,omitempty"`
+	Const                interface{}        `json:"const,omitempty"`
+	Not                  *Schema            `json:"not,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	Defs                 map[string]*Schema `json:"$defs,omitempty"`
+	Expr                 string             `json:"x-tageval-expr,omitempty"`
+	Regexp               string             `json:"x-tageval-regexp,omitempty"`
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+	defNameRE = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+)
+
+// Schema generates a JSON Schema for the Go type, which will normally be a
+// struct or pointer to struct.  Property names, skipped fields and the
+// required list follow the Validator's Convention: a field is required
+// unless it is omitted when empty, or it has a `required:"true"` tag.
+// Each named struct type is placed in "$defs" and referenced from where
+// it is used, with the root schema referencing the type itself.  Where
+// encoding/json writes null, for nil pointers, slices and maps, the
+// schema is an "anyOf" allowing null, and the rules apply to the other
+// branch.  A field with the ",string" option is a string, with a pattern
+// for the encoding of its type, and its rules are only recorded in the
+// extensions, as they apply to the value it encodes.
+//
+// The regexp tags become "pattern" keywords, translated to the ECMA-262
+// syntax JSON Schema uses (assuming Unicode mode), and shortcut relational
+// expressions with a literal right-hand side, such as `expr:"> 5"`, map
+// to the corresponding numeric bounds or "const".  Strings are only
+// mapped for equality, as bounds apply to numbers alone.  Any other
+// expression is recorded in full in the "x-tageval-expr" extension.
+func (v Validator) Schema(t reflect.Type) (*Schema, error) {
+	sg := &schemaGen{v: v, rules: v.currentRules(), refPrefix: "#/$defs/",
+		defs: make(map[string]*Schema), names: make(map[reflect.Type]string)}
+
+	// The root describes a value, not what a nil pointer to it encodes.
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	s, err := sg.schema(t)
+	if err != nil {
+		return nil, err
+	}
+	s.Schema = SchemaDraft
+	if len(sg.defs) > 0 {
+		s.Defs = sg.defs
+	}
+	return s, nil
+}
+
+// A schemaGen holds the named struct definitions while generating.  If
+// keepRules is set, the original expressions and regexps are recorded in
+// the extensions even when they could be mapped to keywords.
+type schemaGen struct {
+	v         Validator
+	rules     *RuleSet
+	refPrefix string
+	keepRules bool
+	defs      map[string]*Schema
+	names     map[reflect.Type]string
+}
+
+// schema returns the schema for a Go type, allowing null if the type is
+// a pointer, slice or map.
+func (sg *schemaGen) schema(t reflect.Type) (*Schema, error) {
+	var ptr bool
+	for t.Kind() == reflect.Ptr {
+		t, ptr = t.Elem(), true
+	}
+	s, err := sg.valueSchema(t)
+	if err != nil {
+		return nil, err
+	}
+	if ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Map {
+		s = orNull(s)
+	}
+	return s, nil
+}
+
+// orNull returns a schema allowing null as well as what s allows, unless
+// s already allows anything.
+func orNull(s *Schema) *Schema {
+	if s.Type == "" && s.Ref == "" && s.AnyOf == nil {
+		return s
+	}
+	return &Schema{AnyOf: []*Schema{s, {Type: "null"}}}
+}
+
+// nonNull returns the branch of a schema made by orNull that is not
+// null, or the schema itself.
+func nonNull(s *Schema) *Schema {
+	if len(s.AnyOf) == 2 && s.AnyOf[1].Type == "null" {
+		return s.AnyOf[0]
+	}
+	return s
+}
+
+// valueSchema returns the schema for a Go type other than a pointer,
+// not allowing null.
+func (sg *schemaGen) valueSchema(t reflect.Type) (*Schema, error) {
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}, nil
+	case t.Implements(jsonMarshalerType),
+		reflect.PointerTo(t).Implements(jsonMarshalerType):
+		// Could be anything at all.
+		return &Schema{}, nil
+	case t.Implements(textMarshalerType),
+		reflect.PointerTo(t).Implements(textMarshalerType):
+		return &Schema{Type: "string"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		return &Schema{Type: "integer"}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+		zero := 0.0
+		return &Schema{Type: "integer", Minimum: &zero}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}, nil
+	case reflect.String:
+		return &Schema{Type: "string"}, nil
+	case reflect.Interface:
+		return &Schema{}, nil
+
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", ContentEncoding: "base64"}, nil
+		}
+		items, err := sg.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		s := &Schema{Type: "array", Items: items}
+		if t.Kind() == reflect.Array {
+			n := t.Len()
+			s.MinItems, s.MaxItems = &n, &n
+		}
+		return s, nil
+
+	case reflect.Map:
+		values, err := sg.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "object", AdditionalProperties: values}, nil
+
+	case reflect.Struct:
+		if t.Name() == "" {
+			return sg.structSchema(t)
+		}
+		name, ok := sg.names[t]
+		if !ok {
+			name = sg.defName(t)
+			sg.names[t] = name
+
+			// Reserve the name before generating, for recursive types.
+			sg.defs[name] = nil
+			s, err := sg.structSchema(t)
+			if err != nil {
+				return nil, err
+			}
+			sg.defs[name] = s
+		}
+		return &Schema{Ref: sg.refPrefix + name}, nil
+	}
+	return nil, fmt.Errorf("no JSON Schema for type %v", t)
+}
+
+// defName picks a unique definition name for a named type.
+func (sg *schemaGen) defName(t reflect.Type) string {
+	name := defNameRE.ReplaceAllString(t.Name(), "_")
+	if _, taken := sg.defs[name]; taken {
+		pkg := t.PkgPath()
+		pkg = pkg[strings.LastIndex(pkg, "/")+1:]
+		name = defNameRE.ReplaceAllString(pkg+"."+t.Name(), "_")
+		for base, i := name, 2; ; i++ {
+			if _, taken := sg.defs[name]; !taken {
+				break
+			}
+			name = base + strconv.Itoa(i)
+		}
+	}
+	return name
+}
+
+// structSchema returns the object schema for a struct type, with its
+// fields as properties.
+func (sg *schemaGen) structSchema(t reflect.Type) (*Schema, error) {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		fi := FieldInfo{Name: f.Name}
+		if sg.v.conv != nil {
+			fi = sg.v.conv.Field(f)
+		}
+		if fi.Skip {
+			continue
+		}
+
+		if fi.Inline {
+			et := f.Type
+			if et.Kind() == reflect.Ptr {
+				et = et.Elem()
+			}
+			es, err := sg.structSchema(et)
+			if err != nil {
+				return nil, err
+			}
+			for k, p := range es.Properties {
+				if _, ok := s.Properties[k]; !ok {
+					s.Properties[k] = p
+				}
+			}
+			s.Required = append(s.Required, es.Required...)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+
+		quoted := fi.Quoted && quotable(f.Type)
+		var fs *Schema
+		if quoted {
+			fs = quotedSchema(f.Type)
+		} else {
+			var err error
+			fs, err = sg.schema(f.Type)
+			if err != nil {
+				return nil, fmt.Errorf("field '%s': %v", f.Name, err)
+			}
+		}
+		if err := sg.addRules(nonNull(fs), t, f, quoted); err != nil {
+			return nil, err
+		}
+		fs.Description = f.Tag.Get(DocTag)
+		s.Properties[fi.Name] = fs
+
+		required, _ := strconv.ParseBool(sg.v.rule(sg.rules, t, f, RequiredRule))
+		if required || (sg.v.conv != nil && !fi.OmitEmpty) {
+			s.Required = append(s.Required, fi.Name)
+		}
+	}
+	return s, nil
+}
+
+// quotedSchema returns the schema for a field of a type quotable says
+// the ",string" option applies to: a string holding the JSON encoding of
+// the value, or null for a nil pointer.
+func quotedSchema(t reflect.Type) *Schema {
+	var ptr bool
+	if t.Name() == "" && t.Kind() == reflect.Ptr {
+		t, ptr = t.Elem(), true
+	}
+	s := &Schema{Type: "string"}
+	switch t.Kind() {
+	case reflect.Bool:
+		s.Pattern = "^(true|false)$"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		s.Pattern = "^-?[0-9]+$"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+		s.Pattern = "^[0-9]+$"
+	case reflect.Float32, reflect.Float64:
+		s.Pattern = `^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`
+	case reflect.String:
+		s.Pattern = `^".*"$`
+	}
+	if ptr {
+		return orNull(s)
+	}
+	return s
+}
+
+// addRules maps the validation tags of a field of the struct type st, or
+// the rules added in their place, onto its schema.  The rules of a quoted
+// field apply to the value it encodes, so are only recorded in the
+// extensions.
+func (sg *schemaGen) addRules(s *Schema, st reflect.Type,
+	f reflect.StructField, quoted bool) error {
+	if pattern := sg.v.rule(sg.rules, st, f, RegexpRule); pattern != "" {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("field '%s': %v", f.Name, err)
+		}
+		if s.Type == "string" && !quoted {
+			// JSON Schema patterns are in JavaScript syntax.
+			s.Pattern = pattern
+			if src, err := jsRegexp(pattern); err == nil {
+				s.Pattern = src
+			}
+		}
+		if s.Type != "string" || quoted || sg.keepRules {
+			s.Regexp = pattern
+		}
+	}
+
+	expr := sg.v.rule(sg.rules, st, f, ExprRule)
+	if expr == "" {
+		return nil
+	}
+	if quoted || !shortcutSchema(s, expr) || sg.keepRules {
+		s.Expr = ExpandShortcut(f.Name, expr)
+	}
+	return nil
+}
+
+// shortcutSchema applies a shortcut relational expression with a literal
+// right-hand side to the schema, and reports whether it could.
+func shortcutSchema(s *Schema, expr string) bool {
+	op, rhs, ok := splitShortcut(expr)
+	if !ok {
+		return false
+	}
+
+	// JSON can't hold the non-finite numbers ParseFloat accepts.
+	var lit interface{}
+	n, err := strconv.ParseFloat(rhs, 64)
+	if err == nil && !math.IsInf(n, 0) && !math.IsNaN(n) {
+		if s.Type != "integer" && s.Type != "number" {
+			return false
+		}
+		lit = n
+	} else if str, ok := jsStringLiteral(rhs); ok && s.Type == "string" {
+		lit = str
+	} else {
+		return false
+	}
+
+	// The bounds are numeric; strings may only be compared for equality.
+	if _, isStr := lit.(string); isStr {
+		switch op {
+		case "==", "===", "!=", "!==":
+		default:
+			return false
+		}
+	}
+
+	switch op {
+	case "<":
+		s.ExclusiveMaximum = &n
+	case "<=":
+		s.Maximum = &n
+	case ">":
+		s.ExclusiveMinimum = &n
+	case ">=":
+		s.Minimum = &n
+	case "==", "===":
+		s.Const = lit
+	case "!=", "!==":
+		s.Not = &Schema{Const: lit}
+	default:
+		return false
+	}
+	return true
+}
+
+// jsStringLiteral unquotes a simple single- or double-quoted JavaScript
+// string literal without escapes.
+func jsStringLiteral(lit string) (string, bool) {
+	if len(lit) < 2 || strings.ContainsRune(lit, '\\') {
+		return "", false
+	}
+	q := lit[0]
+	if (q != '\'' && q != '"') || lit[len(lit)-1] != q ||
+		strings.IndexByte(lit[1:len(lit)-1], q) >= 0 {
+		return "", false
+	}
+	return lit[1 : len(lit)-1], true
+}
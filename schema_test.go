@@ -0,0 +1,297 @@
+package tageval
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+type Line struct {
+	SKU      string `json:"sku" regexp:"^[A-Z]{3}-[0-9]+$"`
+	Quantity int    `json:"qty" expr:"> 0"`
+}
+
+type Order struct {
+	ID      string    `json:"id"`
+	Placed  time.Time `json:"placed"`
+	Lines   []Line    `json:"lines" expr:"Lines.length <= 100"`
+	Status  string    `json:"status,omitempty" expr:"!= 'void'"`
+	Total   float64   `json:"total,omitempty" expr:"<= 1e6"`
+	Count   uint      `json:"count,omitempty" required:"true" regexp:"^[0-9]+$"`
+	Next    *Order    `json:"next,omitempty"`
+	Private string    `json:"-" expr:"> 2"`
+}
+
+func TestSchema(t *testing.T) {
+	v, _ := NewValidator()
+	s, err := v.Schema(reflect.TypeOf(&Order{}))
+	if err != nil {
+		t.Fatalf("schema generation failed with error: %v", err)
+	}
+	b := marshalSchema(t, s)
+
+	expected := `{"$schema":"https://json-schema.org/draft/2020-12/schema",` +
+		`"$ref":"#/$defs/Order","$defs":{` +
+		`"Line":{"type":"object","properties":{` +
+		`"qty":{"type":"integer","exclusiveMinimum":0},` +
+		`"sku":{"type":"string","pattern":"^[A-Z]{3}-[0-9]+$"}},` +
+		`"required":["sku","qty"]},` +
+		`"Order":{"type":"object","properties":{` +
+		`"count":{"type":"integer","minimum":0,"x-tageval-regexp":"^[0-9]+$"},` +
+		`"id":{"type":"string"},` +
+		`"lines":{"anyOf":[{"type":"array","items":{"$ref":"#/$defs/Line"},` +
+		`"x-tageval-expr":"Lines.length <= 100"},{"type":"null"}]},` +
+		`"next":{"anyOf":[{"$ref":"#/$defs/Order"},{"type":"null"}]},` +
+		`"placed":{"type":"string","format":"date-time"},` +
+		`"status":{"type":"string","not":{"const":"void"}},` +
+		`"total":{"type":"number","maximum":1000000}},` +
+		`"required":["id","placed","lines","count"]}}}`
+	if b != expected {
+		t.Fatalf("unexpected schema:\n%s\nexpected:\n%s", b, expected)
+	}
+}
+
+func TestSchemaTagNames(t *testing.T) {
+	type Renamed struct {
+		A int    `json:"a" tv-expr:">= 3" expr:"A == 0"`
+		B string `json:"b" tv-re:"^x$"`
+	}
+	v, _ := NewValidator(ExprTagName("tv-expr"), RegexpTagName("tv-re"))
+	s, err := v.Schema(reflect.TypeOf(Renamed{}))
+	if err != nil {
+		t.Fatalf("schema generation failed with error: %v", err)
+	}
+	def := s.Defs["Renamed"]
+	if def == nil || def.Properties["a"].Minimum == nil ||
+		*def.Properties["a"].Minimum != 3 || def.Properties["a"].Expr != "" ||
+		def.Properties["b"].Pattern != "^x$" {
+		t.Fatalf("unexpected schema: %s", marshalSchema(t, s))
+	}
+}
+
+func TestSchemaShortcuts(t *testing.T) {
+	type Bounds struct {
+		Code  string  `json:"code" expr:"> 'm'"`
+		Name  string  `json:"name" expr:"== 'x'"`
+		Limit float64 `json:"limit" expr:"< Infinity"`
+		Min   int     `json:"min" expr:">= -5"`
+	}
+	v, _ := NewValidator()
+	s, err := v.Schema(reflect.TypeOf(Bounds{}))
+	if err != nil {
+		t.Fatalf("schema generation failed with error: %v", err)
+	}
+	b := marshalSchema(t, s)
+	expected := `"code":{"type":"string","x-tageval-expr":"Code > 'm'"},` +
+		`"limit":{"type":"number","x-tageval-expr":"Limit < Infinity"},` +
+		`"min":{"type":"integer","minimum":-5},` +
+		`"name":{"type":"string","const":"x"}}`
+	if !strings.Contains(b, expected) {
+		t.Fatalf("unexpected schema:\n%s\nexpected to contain:\n%s", b,
+			expected)
+	}
+}
+
+type Nullables struct {
+	Items  []Line            `json:"items" expr:"Items.length <= 100"`
+	Next   *Nullables        `json:"next"`
+	Sub    *Line             `json:"sub" expr:"Sub.qty < 10"`
+	Counts map[string]int    `json:"counts"`
+	Data   []byte            `json:"data"`
+	Rate   *float64          `json:"rate" expr:"> 0"`
+	Any    interface{}       `json:"any"`
+	Qty    int               `json:"qty,string" expr:"> 0"`
+	Ready  *bool             `json:"ready,string"`
+	Name   string            `json:"name,string" regexp:"^[a-z]+$"`
+	Lines  map[string]*Line  `json:"lines"`
+	Times  [2]time.Time      `json:"times"`
+	Nested map[string][]Line `json:"nested"`
+}
+
+func TestSchemaNullAndQuoted(t *testing.T) {
+	v, _ := NewValidator()
+	s, err := v.Schema(reflect.TypeOf(Nullables{}))
+	if err != nil {
+		t.Fatalf("schema generation failed with error: %v", err)
+	}
+	b := marshalSchema(t, s)
+	for _, expected := range []string{
+		`"qty":{"type":"string","pattern":"^-?[0-9]+$","x-tageval-expr":"Qty > 0"}`,
+		`"ready":{"anyOf":[{"type":"string","pattern":"^(true|false)$"},` +
+			`{"type":"null"}]}`,
+		`"name":{"type":"string","pattern":"^\".*\"$",` +
+			`"x-tageval-regexp":"^[a-z]+$"}`,
+		`"rate":{"anyOf":[{"type":"number","exclusiveMinimum":0},` +
+			`{"type":"null"}]}`,
+		`"any":{}`,
+	} {
+		if !strings.Contains(b, expected) {
+			t.Fatalf("unexpected schema:\n%s\nexpected to contain:\n%s", b,
+				expected)
+		}
+	}
+
+	// What encoding/json writes must meet the schema, for the zero value
+	// in particular.
+	rate, ready := 0.5, true
+	for _, item := range []Nullables{
+		{},
+		{Items: []Line{{"ABC-1", 2}}, Next: &Nullables{},
+			Sub: &Line{"ABC-1", 2}, Counts: map[string]int{"a": 1},
+			Data: []byte("x"), Rate: &rate, Any: []int{1}, Qty: 3,
+			Ready: &ready, Name: "abc", Lines: map[string]*Line{"a": nil},
+			Nested: map[string][]Line{"a": nil}},
+	} {
+		data, err := json.Marshal(item)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var doc interface{}
+		if err := json.Unmarshal(data, &doc); err != nil {
+			t.Fatal(err)
+		}
+		if err := schemaCheck(s, s, doc, ""); err != nil {
+			t.Fatalf("%s fails schema: %v\n%s", data, err, b)
+		}
+	}
+
+	// A quoted value that is not the encoding of its type doesn't.
+	if err := schemaCheck(s, s.Defs["Nullables"].Properties["qty"], "x",
+		"/qty"); err == nil {
+		t.Fatalf("quoted value wrongly accepted")
+	}
+}
+
+// schemaCheck checks a decoded JSON document against a schema, for the
+// keywords Schema generates, returning the first violation.
+func schemaCheck(root, s *Schema, doc interface{}, path string) error {
+	fail := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%s: %s", path, fmt.Sprintf(format, args...))
+	}
+	if s.Ref != "" {
+		name := strings.TrimPrefix(s.Ref, "#/$defs/")
+		def, ok := root.Defs[name]
+		if !ok {
+			return fail("unknown reference %s", s.Ref)
+		}
+		if err := schemaCheck(root, def, doc, path); err != nil {
+			return err
+		}
+	}
+	if s.AnyOf != nil {
+		var err error
+		for _, as := range s.AnyOf {
+			if err = schemaCheck(root, as, doc, path); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if s.Not != nil && schemaCheck(root, s.Not, doc, path) == nil {
+		return fail("matches not")
+	}
+	if s.Const != nil && !reflect.DeepEqual(s.Const, doc) {
+		return fail("%v is not %v", doc, s.Const)
+	}
+
+	switch s.Type {
+	case "":
+	case "null":
+		if doc != nil {
+			return fail("%v is not null", doc)
+		}
+	case "boolean":
+		if _, ok := doc.(bool); !ok {
+			return fail("%v is not a boolean", doc)
+		}
+	case "integer", "number":
+		n, ok := doc.(float64)
+		if !ok || (s.Type == "integer" && n != math.Trunc(n)) {
+			return fail("%v is not an %s", doc, s.Type)
+		}
+		if (s.Minimum != nil && n < *s.Minimum) ||
+			(s.Maximum != nil && n > *s.Maximum) ||
+			(s.ExclusiveMinimum != nil && n <= *s.ExclusiveMinimum) ||
+			(s.ExclusiveMaximum != nil && n >= *s.ExclusiveMaximum) {
+			return fail("%v is out of bounds", n)
+		}
+	case "string":
+		str, ok := doc.(string)
+		if !ok {
+			return fail("%v is not a string", doc)
+		}
+		if s.Pattern != "" && !regexp.MustCompile(s.Pattern).MatchString(str) {
+			return fail("%q does not match %s", str, s.Pattern)
+		}
+	case "array":
+		arr, ok := doc.([]interface{})
+		if !ok {
+			return fail("%v is not an array", doc)
+		}
+		if (s.MinItems != nil && len(arr) < *s.MinItems) ||
+			(s.MaxItems != nil && len(arr) > *s.MaxItems) {
+			return fail("wrong number of items")
+		}
+		for i, e := range arr {
+			if err := schemaCheck(root, s.Items, e,
+				fmt.Sprintf("%s/%d", path, i)); err != nil {
+				return err
+			}
+		}
+	case "object":
+		obj, ok := doc.(map[string]interface{})
+		if !ok {
+			return fail("%v is not an object", doc)
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				return fail("%s is required", name)
+			}
+		}
+		for k, e := range obj {
+			ps, ok := s.Properties[k]
+			if !ok {
+				ps = s.AdditionalProperties
+			}
+			if ps == nil {
+				continue
+			}
+			if err := schemaCheck(root, ps, e, path+"/"+k); err != nil {
+				return err
+			}
+		}
+	default:
+		return fail("unknown type %s", s.Type)
+	}
+	return nil
+}
+
+func TestSchemaUnsupported(t *testing.T) {
+	type Chans struct {
+		C chan int
+	}
+	v, _ := NewValidator()
+	if _, err := v.Schema(reflect.TypeOf(Chans{})); err == nil {
+		t.Fatalf("did not receive expected error")
+	}
+}
+
+// marshalSchema renders the schema without HTML escaping, for
+// readability of the expected strings.
+func marshalSchema(t *testing.T, s interface{}) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(s); err != nil {
+		t.Fatalf("schema marshaling failed with error: %v", err)
+	}
+	return strings.TrimSpace(buf.String())
+}
@@ -0,0 +1,621 @@
+// Package tagevalgen generates reflection-free validation methods for
+// structs with tageval tags.  For each struct type it writes a
+//
+//	func (x *T) TagevalValidate() []tageval.Result
+//
+// method, which the tageval Validator uses in preference to reflection
+// and the JavaScript engine.  The method covers the exported fields of
+// boolean, numeric and string types (or pointers to them), with:
+//   - regexp tags, compiled once with the regexp package,
+//   - relational shortcuts such as `expr:"> 5"`,
+//   - expressions built from the field itself, its "length" if it is a
+//     string, literals, and the arithmetic, comparison and logical
+//     operators, with the same results as in JavaScript.
+//
+// Anything else is left to the JavaScript engine at run time.  JSON rules
+// are assumed, so fields tagged `json:"-"` are left out, and the rules of
+// an omitempty field holding the zero value are not evaluated.  The tag
+// names may be changed with GenerateWith, to match those the Validator
+// is given.
+//
+// It is run by the cmd/tagevalgen command, normally from go generate.
+package tagevalgen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/types"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gdotgordon/tageval"
+	jsast "github.com/robertkrimen/otto/ast"
+	"github.com/robertkrimen/otto/parser"
+	"github.com/robertkrimen/otto/token"
+	"golang.org/x/tools/go/packages"
+)
+
+// LoadMode is the packages.LoadMode needed by Generate.  Dependencies
+// are type checked from source, rather than relying on export data from
+// the installed toolchain.
+const LoadMode = packages.NeedName | packages.NeedFiles |
+	packages.NeedImports | packages.NeedDeps | packages.NeedTypes |
+	packages.NeedSyntax
+
+// Options give the names of the struct tags read by GenerateWith,
+// matching the Validator's ExprTagName and RegexpTagName options and the
+// tag of its TagConvention.  An empty name means the default.
+type Options struct {
+	ExprTag       string // tageval.ExprTag by default
+	RegexpTag     string // tageval.RegexpTag by default
+	ConventionTag string // "json" by default
+}
+
+// Generate returns the formatted source of a file for the package,
+// declaring a TagevalValidate method for each of the named struct
+// types, or if no names are given, for every struct type with a rule
+// that can be generated.
+func Generate(pkg *packages.Package, typeNames []string) ([]byte, error) {
+	return GenerateWith(pkg, typeNames, Options{})
+}
+
+// GenerateWith is like Generate, but reads the tags named by the options.
+func GenerateWith(pkg *packages.Package, typeNames []string,
+	opts Options) ([]byte, error) {
+	if opts.ExprTag == "" {
+		opts.ExprTag = tageval.ExprTag
+	}
+	if opts.RegexpTag == "" {
+		opts.RegexpTag = tageval.RegexpTag
+	}
+	if opts.ConventionTag == "" {
+		opts.ConventionTag = "json"
+	}
+	g := &generator{opts: opts, imports: make(map[string]bool)}
+
+	auto := len(typeNames) == 0
+	if auto {
+		scope := pkg.Types.Scope()
+		for _, name := range scope.Names() {
+			if _, ok := scope.Lookup(name).(*types.TypeName); ok {
+				typeNames = append(typeNames, name)
+			}
+		}
+	}
+	for _, name := range typeNames {
+		obj, ok := pkg.Types.Scope().Lookup(name).(*types.TypeName)
+		if !ok {
+			return nil, fmt.Errorf("type %s not found in package %s", name,
+				pkg.PkgPath)
+		}
+		named, ok := obj.Type().(*types.Named)
+		st, isStruct := obj.Type().Underlying().(*types.Struct)
+		if !ok || !isStruct || named.TypeParams().Len() > 0 || obj.IsAlias() {
+			if auto {
+				continue
+			}
+			return nil, fmt.Errorf("%s is not a non-generic struct type", name)
+		}
+		if err := g.structMethod(name, st, !auto); err != nil {
+			return nil, err
+		}
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by tagevalgen; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&out, "package %s\n\n", pkg.Name)
+	var imports []string
+	for imp := range g.imports {
+		imports = append(imports, imp)
+	}
+	sort.Slice(imports, func(i, j int) bool {
+		si := strings.Contains(imports[i], ".")
+		sj := strings.Contains(imports[j], ".")
+		if si != sj {
+			return sj
+		}
+		return imports[i] < imports[j]
+	})
+	out.WriteString("import (\n")
+	for i, imp := range imports {
+		// The standard library sorts first, as it has no dots.
+		std := !strings.Contains(imp, ".")
+		if i > 0 && !std && !strings.Contains(imports[i-1], ".") {
+			out.WriteString("\n")
+		}
+		fmt.Fprintf(&out, "\t%q\n", imp)
+	}
+	out.WriteString(")\n\n")
+	if len(g.patterns) > 0 {
+		out.WriteString("var (\n")
+		for i, p := range g.patterns {
+			fmt.Fprintf(&out, "\ttagevalRegexp%d = regexp.MustCompile(%s)\n",
+				i, goQuote(p))
+		}
+		out.WriteString(")\n\n")
+	}
+	if g.body.Len() == 0 {
+		return nil, fmt.Errorf("no struct types with rules in package %s",
+			pkg.PkgPath)
+	}
+	out.Write(g.body.Bytes())
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %v", err)
+	}
+	return src, nil
+}
+
+// A generator collects the methods, and the regexps and imports they
+// need, while generating.
+type generator struct {
+	opts     Options
+	body     bytes.Buffer
+	patterns []string
+	imports  map[string]bool
+}
+
+// A fieldRule is the Go code for one rule on a field, and the id the
+// Code of its Results.
+type fieldRule struct {
+	kind tageval.RuleKind
+	expr string
+	code string
+	sev  tageval.Severity
+	id   string
+}
+
+// structMethod writes the method for a struct type.  Unless always is
+// set, nothing is written if none of the rules can be generated.
+func (g *generator) structMethod(name string, st *types.Struct,
+	always bool) error {
+	var b bytes.Buffer
+	n := 0
+	for i := 0; i < st.NumFields(); i++ {
+		f := st.Field(i)
+		rules, err := g.fieldRules(name, f, reflect.StructTag(st.Tag(i)))
+		if err != nil {
+			return fmt.Errorf("%s.%s: %v", name, f.Name(), err)
+		}
+		if len(rules) == 0 {
+			continue
+		}
+		n += len(rules)
+		g.writeField(&b, f, reflect.StructTag(st.Tag(i)), rules)
+	}
+	if n == 0 && !always {
+		return nil
+	}
+
+	g.imports["github.com/gdotgordon/tageval"] = true
+	fmt.Fprintf(&g.body, "// TagevalValidate checks the tags of %s without reflection.\n",
+		name)
+	fmt.Fprintf(&g.body, "func (x *%s) TagevalValidate() []tageval.Result {\n",
+		name)
+	if n == 0 {
+		g.body.WriteString("return nil\n}\n\n")
+		return nil
+	}
+	g.body.WriteString("var res []tageval.Result\n")
+	g.body.Write(b.Bytes())
+	g.body.WriteString("return res\n}\n\n")
+	return nil
+}
+
+// fieldRules returns the rules of a field that can be generated.
+func (g *generator) fieldRules(typeName string, f *types.Var,
+	tag reflect.StructTag) (
+	[]fieldRule, error) {
+	if !f.Exported() || f.Embedded() || tag.Get(g.opts.ConventionTag) == "-" {
+		return nil, nil
+	}
+	t := f.Type()
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	kind := valueKind(t)
+	if kind == 0 {
+		return nil, nil
+	}
+
+	var rules []fieldRule
+	if pattern := tag.Get(g.opts.RegexpTag); pattern != "" {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return nil, err
+		}
+		if str := stringOf(t, "v"); str != "" {
+			g.imports["regexp"] = true
+			if strings.HasPrefix(str, "strconv.") {
+				g.imports["strconv"] = true
+			}
+			re := fmt.Sprintf("tagevalRegexp%d", len(g.patterns))
+			g.patterns = append(g.patterns, pattern)
+			rules = append(rules, fieldRule{kind: tageval.RegexpRule,
+				expr: pattern, code: re + ".MatchString(" + str + ")"})
+		}
+	}
+	if expr := tag.Get(g.opts.ExprTag); expr != "" {
+		full := tageval.ExpandShortcut(f.Name(), expr)
+		c := &exprCompiler{name: f.Name(), typ: t, kind: kind}
+		if code, ok := c.program(full); ok {
+			if c.math {
+				g.imports["math"] = true
+			}
+			rules = append(rules, fieldRule{kind: tageval.ExprRule,
+				expr: full, code: code})
+		}
+	}
+	for i := range rules {
+		sev, err := tageval.SeverityOf(tag, rules[i].kind)
+		if err != nil {
+			return nil, err
+		}
+		rules[i].sev = sev
+		id, err := tageval.CodeOf(tag, typeName, f.Name(), rules[i].kind)
+		if err != nil {
+			return nil, err
+		}
+		rules[i].id = id
+	}
+	return rules, nil
+}
+
+// writeField writes the code evaluating the rules of a field, guarded
+// as the Validator would guard them.
+func (g *generator) writeField(b *bytes.Buffer, f *types.Var,
+	tag reflect.StructTag, rules []fieldRule) {
+	name, opts, _ := strings.Cut(tag.Get(g.opts.ConventionTag), ",")
+	if name == "" {
+		name = f.Name()
+	}
+	var omitEmpty bool
+	for _, opt := range strings.Split(opts, ",") {
+		omitEmpty = omitEmpty || opt == "omitempty"
+	}
+
+	x := "x." + f.Name()
+	_, ptr := f.Type().(*types.Pointer)
+	t := f.Type()
+	if ptr {
+		t = t.(*types.Pointer).Elem()
+	}
+	zero := map[jsKind]string{jsBool: "false", jsNumber: "0",
+		jsString: `""`}[valueKind(t)]
+	switch {
+	case ptr && omitEmpty:
+		fmt.Fprintf(b, "if %s != nil && *%s != %s {\nv := *%s\n", x, x, zero, x)
+	case ptr:
+		fmt.Fprintf(b, "if %s != nil {\nv := *%s\n", x, x)
+	case omitEmpty:
+		fmt.Fprintf(b, "if v := %s; v != %s {\n", x, zero)
+	default:
+		fmt.Fprintf(b, "{\nv := %s\n", x)
+	}
+
+	path := "/" + strings.ReplaceAll(strings.ReplaceAll(name, "~", "~0"),
+		"/", "~1")
+	value := "v"
+	if tageval.IsSensitive(tag) {
+		value = "tageval.Redacted, Redacted: true"
+	}
+	for _, r := range rules {
+		kind := "tageval.ExprRule"
+		if r.kind == tageval.RegexpRule {
+			kind = "tageval.RegexpRule"
+		}
+		fmt.Fprintf(b, "res = append(res, tageval.Result{Name: %q, Path: %q,\n",
+			f.Name(), path)
+		fmt.Fprintf(b, "Value: %s, Kind: %s, Expr: %s,\nValid: %s,\nSeverity: %s, Code: %q})\n",
+			value, kind, goQuote(r.expr), r.code, severityNames[r.sev], r.id)
+	}
+	b.WriteString("}\n")
+}
+
+// severityNames are the names of the Severity constants.
+var severityNames = map[tageval.Severity]string{
+	tageval.SeverityError:   "tageval.SeverityError",
+	tageval.SeverityWarning: "tageval.SeverityWarning",
+	tageval.SeverityInfo:    "tageval.SeverityInfo",
+}
+
+// A jsKind is the JavaScript type of a generated value.
+type jsKind int
+
+const (
+	jsBool jsKind = iota + 1
+	jsNumber
+	jsString
+)
+
+// valueKind returns the JavaScript type of values of a Go type, or zero
+// if generated code doesn't handle them.
+func valueKind(t types.Type) jsKind {
+	b, ok := t.Underlying().(*types.Basic)
+	if !ok {
+		return 0
+	}
+	info := b.Info()
+	switch {
+	case info&types.IsBoolean != 0:
+		return jsBool
+	case info&types.IsString != 0:
+		return jsString
+	case info&(types.IsInteger|types.IsFloat) != 0:
+		return jsNumber
+	}
+	return 0
+}
+
+// stringOf returns the code converting a value to a string for a regexp,
+// as the Validator does, or "" if it can't be done.
+func stringOf(t types.Type, v string) string {
+	ms := types.NewMethodSet(t)
+	if sel := ms.Lookup(nil, "String"); sel != nil {
+		if sig, ok := sel.Type().(*types.Signature); ok &&
+			sig.Params().Len() == 0 && sig.Results().Len() == 1 &&
+			types.Identical(sig.Results().At(0).Type(), types.Typ[types.String]) {
+			return v + ".String()"
+		}
+		return ""
+	}
+
+	b := t.Underlying().(*types.Basic)
+	switch info := b.Info(); {
+	case info&types.IsString != 0:
+		return convert(t, types.String, "string", v)
+	case info&types.IsBoolean != 0:
+		return "strconv.FormatBool(" + convert(t, types.Bool, "bool", v) + ")"
+	case info&types.IsUnsigned != 0:
+		return "strconv.FormatUint(" + convert(t, types.Uint64, "uint64", v) +
+			", 10)"
+	case info&types.IsInteger != 0:
+		return "strconv.FormatInt(" + convert(t, types.Int64, "int64", v) +
+			", 10)"
+	case b.Kind() == types.Float32:
+		return "strconv.FormatFloat(float64(" + v + "), 'g', -1, 32)"
+	default:
+		return "strconv.FormatFloat(" + convert(t, types.Float64, "float64", v) +
+			", 'g', -1, 64)"
+	}
+}
+
+// convert returns the code converting v to the basic type, unless it
+// already is that type.
+func convert(t types.Type, kind types.BasicKind, name, v string) string {
+	if types.Identical(t, types.Typ[kind]) {
+		return v
+	}
+	return name + "(" + v + ")"
+}
+
+// goQuote quotes a string as a Go literal, preferring a raw string.
+func goQuote(s string) string {
+	if strconv.CanBackquote(s) {
+		return "`" + s + "`"
+	}
+	return strconv.Quote(s)
+}
+
+// An exprCompiler translates a JavaScript expression over a single
+// field into Go.  Arithmetic is done in float64, as in JavaScript, with
+// each result converted explicitly to prevent fused operations.  Any
+// expression outside the supported subset is rejected, to be left to
+// the JavaScript engine.
+type exprCompiler struct {
+	name string
+	typ  types.Type
+	kind jsKind
+	math bool
+}
+
+// A goExpr is a translated expression.  Its precedence is that of its
+// operator in Go, constant is set if it involves no variables.
+type goExpr struct {
+	code     string
+	kind     jsKind
+	prec     int
+	constant bool
+}
+
+// Precedences of operands that never need parentheses in a binary
+// expression, although a unary one does as the operand of another.
+const (
+	unaryPrec = 5
+	atomPrec  = 6
+)
+
+// program translates a whole expression, which must be a single
+// expression statement with a boolean result.
+func (c *exprCompiler) program(src string) (string, bool) {
+	prog, err := parser.ParseFile(nil, "", src, 0)
+	if err != nil || len(prog.Body) != 1 {
+		return "", false
+	}
+	es, ok := prog.Body[0].(*jsast.ExpressionStatement)
+	if !ok {
+		return "", false
+	}
+	e, ok := c.expr(es.Expression)
+	if !ok || e.kind != jsBool {
+		return "", false
+	}
+	return e.code, true
+}
+
+func (c *exprCompiler) expr(n jsast.Expression) (goExpr, bool) {
+	switch n := n.(type) {
+	case *jsast.Identifier:
+		if n.Name != c.name {
+			return goExpr{}, false
+		}
+		var code string
+		switch c.kind {
+		case jsBool:
+			code = convert(c.typ, types.Bool, "bool", "v")
+		case jsNumber:
+			code = convert(c.typ, types.Float64, "float64", "v")
+		case jsString:
+			code = convert(c.typ, types.String, "string", "v")
+		}
+		return goExpr{code: code, kind: c.kind, prec: atomPrec}, true
+
+	case *jsast.DotExpression:
+		left, ok := c.expr(n.Left)
+		if !ok || left.kind != jsString || n.Identifier.Name != "length" {
+			return goExpr{}, false
+		}
+		return goExpr{code: "float64(tageval.JSLength(" + left.code + "))",
+			kind: jsNumber, prec: atomPrec, constant: left.constant}, true
+
+	case *jsast.NumberLiteral:
+		var f float64
+		switch v := n.Value.(type) {
+		case int64:
+			f = float64(v)
+		case float64:
+			f = v
+		default:
+			return goExpr{}, false
+		}
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			return goExpr{}, false
+		}
+		return goExpr{code: strconv.FormatFloat(f, 'g', -1, 64),
+			kind: jsNumber, prec: atomPrec, constant: true}, true
+
+	case *jsast.StringLiteral:
+		return goExpr{code: strconv.Quote(n.Value), kind: jsString,
+			prec: atomPrec, constant: true}, true
+
+	case *jsast.BooleanLiteral:
+		return goExpr{code: strconv.FormatBool(n.Value), kind: jsBool,
+			prec: atomPrec, constant: true}, true
+
+	case *jsast.UnaryExpression:
+		operand, ok := c.expr(n.Operand)
+		if !ok || n.Postfix {
+			return goExpr{}, false
+		}
+		var code string
+		switch {
+		case n.Operator == token.NOT && operand.kind == jsBool:
+			code = "!" + paren(operand, atomPrec)
+		case n.Operator == token.MINUS && operand.kind == jsNumber:
+			code = "-" + paren(operand, atomPrec)
+		default:
+			return goExpr{}, false
+		}
+		return goExpr{code: code, kind: operand.kind, prec: unaryPrec,
+			constant: operand.constant}, true
+
+	case *jsast.BinaryExpression:
+		return c.binary(n)
+	}
+	return goExpr{}, false
+}
+
+// Go operator precedences.
+const (
+	orPrec  = 1
+	andPrec = 2
+	cmpPrec = 3
+)
+
+func (c *exprCompiler) binary(n *jsast.BinaryExpression) (goExpr, bool) {
+	left, ok := c.expr(n.Left)
+	if !ok {
+		return goExpr{}, false
+	}
+	right, ok := c.expr(n.Right)
+	if !ok || left.kind != right.kind {
+		return goExpr{}, false
+	}
+	constant := left.constant && right.constant
+
+	logical := func(op string, prec int) (goExpr, bool) {
+		if left.kind != jsBool {
+			return goExpr{}, false
+		}
+		return goExpr{code: paren(left, prec) + " " + op + " " +
+			paren(right, prec+1), kind: jsBool, prec: prec,
+			constant: constant}, true
+	}
+	compare := func(op string) (goExpr, bool) {
+		return goExpr{code: paren(left, cmpPrec+1) + " " + op + " " +
+			paren(right, cmpPrec+1), kind: jsBool, prec: cmpPrec,
+			constant: constant}, true
+	}
+	arith := func(op string) (goExpr, bool) {
+		// Go evaluates constant expressions exactly, unlike JavaScript.
+		if constant || (left.kind != jsNumber && op != "+") {
+			return goExpr{}, false
+		}
+		if left.kind == jsString {
+			return goExpr{code: "(" + left.code + " + " + right.code + ")",
+				kind: jsString, prec: atomPrec}, true
+		}
+		return goExpr{code: "float64(" + left.code + " " + op + " " +
+			right.code + ")", kind: jsNumber, prec: atomPrec}, true
+	}
+
+	switch n.Operator {
+	case token.LOGICAL_OR:
+		return logical("||", orPrec)
+	case token.LOGICAL_AND:
+		return logical("&&", andPrec)
+	case token.EQUAL, token.STRICT_EQUAL:
+		return compare("==")
+	case token.NOT_EQUAL, token.STRICT_NOT_EQUAL:
+		return compare("!=")
+	case token.LESS, token.LESS_OR_EQUAL, token.GREATER,
+		token.GREATER_OR_EQUAL:
+		// Strings compare by UTF-16 code units in JavaScript.
+		if left.kind != jsNumber {
+			return goExpr{}, false
+		}
+		return compare(n.Operator.String())
+	case token.PLUS:
+		return arith("+")
+	case token.MINUS:
+		return arith("-")
+	case token.MULTIPLY:
+		return arith("*")
+	case token.SLASH:
+		if isZero(right) {
+			return goExpr{}, false
+		}
+		return arith("/")
+	case token.REMAINDER:
+		if constant || left.kind != jsNumber || isZero(right) {
+			return goExpr{}, false
+		}
+		c.math = true
+		return goExpr{code: "math.Mod(" + left.code + ", " + right.code + ")",
+			kind: jsNumber, prec: atomPrec}, true
+	}
+	return goExpr{}, false
+}
+
+// isZero reports whether an expression is a literal zero, possibly
+// negated, which Go rejects as a constant divisor.
+func isZero(e goExpr) bool {
+	if !e.constant {
+		return false
+	}
+	f, err := strconv.ParseFloat(strings.Trim(e.code, "-()"), 64)
+	return err == nil && f == 0
+}
+
+// paren parenthesizes an operand binding less tightly than prec.
+func paren(e goExpr, prec int) string {
+	if e.prec < prec {
+		return "(" + e.code + ")"
+	}
+	return e.code
+}
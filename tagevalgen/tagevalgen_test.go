@@ -0,0 +1,163 @@
+package tagevalgen
+
+import (
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func TestGenerate(t *testing.T) {
+	pkgs, err := packages.Load(&packages.Config{Mode: LoadMode},
+		"./testdata/a")
+	if err != nil {
+		t.Fatalf("loading package failed: %v", err)
+	}
+	if len(pkgs) != 1 || len(pkgs[0].Errors) > 0 {
+		t.Fatalf("loading package failed: %v", pkgs[0].Errors)
+	}
+
+	src, err := Generate(pkgs[0], nil)
+	if err != nil {
+		t.Fatalf("generation failed with error: %v", err)
+	}
+	golden := filepath.Join("testdata", "a_tageval.golden")
+	if *update {
+		if err := os.WriteFile(golden, src, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(src) != string(expected) {
+		t.Fatalf("generated code differs from %s:\n%s", golden, src)
+	}
+
+	// A type named explicitly gets a method, even with no rules to
+	// generate, and anything else is an error.
+	if _, err := Generate(pkgs[0], []string{"Empty"}); err != nil {
+		t.Fatalf("generation failed with error: %v", err)
+	}
+	for _, name := range []string{"Status", "Missing"} {
+		if _, err := Generate(pkgs[0], []string{name}); err == nil {
+			t.Fatalf("expected error for %s", name)
+		}
+	}
+
+	// Renamed tags are read only when given.
+	src, err = GenerateWith(pkgs[0], []string{"Renamed"},
+		Options{ExprTag: "check", ConventionTag: "yaml"})
+	if err != nil {
+		t.Fatalf("generation failed with error: %v", err)
+	}
+	if !strings.Contains(string(src), "x.Qty") ||
+		strings.Contains(string(src), "x.Name") {
+		t.Fatalf("unexpected code for renamed tags:\n%s", src)
+	}
+}
+
+// TestGoldenAgrees builds the golden file into a copy of the package it
+// was generated from, and runs the package's test comparing the generated
+// method with the Validator.
+func TestGoldenAgrees(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a package")
+	}
+	goCmd, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+
+	// The copy must be within the module to import tageval.
+	dir, err := os.MkdirTemp("testdata", "golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files, err := filepath.Glob(filepath.Join("testdata", "a", "*.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	copies := map[string]string{
+		filepath.Join("testdata", "a_tageval.golden"): "a_tageval.go",
+	}
+	for _, f := range files {
+		copies[f] = filepath.Base(f)
+	}
+	for from, to := range copies {
+		data, err := os.ReadFile(from)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, to), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command(goCmd, "test", "-count=1", "-run=TestAgree", "-v",
+		"./"+filepath.Base(dir))
+	cmd.Dir = "testdata"
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("golden package failed: %v\n%s", err, out)
+	}
+	if strings.Contains(string(out), "SKIP") {
+		t.Fatalf("generated method not found:\n%s", out)
+	}
+}
+
+func TestExprCompiler(t *testing.T) {
+	pkgs, err := packages.Load(&packages.Config{Mode: LoadMode},
+		"./testdata/a")
+	if err != nil {
+		t.Fatalf("loading package failed: %v", err)
+	}
+	scope := pkgs[0].Types.Scope()
+	qty := scope.Lookup("Qty").Type()
+	status := scope.Lookup("Status").Type()
+
+	for _, tc := range []struct {
+		expr     string
+		string   bool
+		expected string
+	}{
+		{"A > 5", false, "float64(v) > 5"},
+		{"A - -1 >= -(-A)", false, "float64(float64(v) - -1) >= -(-float64(v))"},
+		{"A == 1 || A == 2 && A != 3", false,
+			"float64(v) == 1 || float64(v) == 2 && float64(v) != 3"},
+		{"(A == 1 || A == 2) && A != 3", false,
+			"(float64(v) == 1 || float64(v) == 2) && float64(v) != 3"},
+		{"A % 3 === 0", false, "math.Mod(float64(v), 3) == 0"},
+		{"A.length > 2", true, "float64(tageval.JSLength(string(v))) > 2"},
+		{"A + 'x' == 'yx'", true, `(string(v) + "x") == "yx"`},
+		{"A > 2 * 3", false, ""},
+		{"A < 'b'", true, ""},
+		{"A == 5", true, ""},
+		{"A > B", false, ""},
+		{"A.trim() == ''", true, ""},
+		{"A > 5; A < 10", false, ""},
+		{"A + 1", false, ""},
+		{"A / 0 > 1", false, ""},
+		{"A / -0 > 1", false, ""},
+		{"A % 0 == 0", false, ""},
+	} {
+		typ, kind := qty, jsNumber
+		if tc.string {
+			typ, kind = status, jsString
+		}
+		c := &exprCompiler{name: "A", typ: typ, kind: kind}
+		code, ok := c.program(tc.expr)
+		if ok != (tc.expected != "") || code != tc.expected {
+			t.Fatalf("%q: expected %q, got %q (%t)", tc.expr, tc.expected,
+				code, ok)
+		}
+	}
+}
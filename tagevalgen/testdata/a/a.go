@@ -0,0 +1,39 @@
+package a
+
+import (
+	"strings"
+	"time"
+)
+
+type Status string
+
+func (s Status) String() string { return strings.ToUpper(string(s)) }
+
+type Qty int
+
+type Order struct {
+	ID      string    `json:"id" regexp:"^[0-9]+$"`
+	Total   float64   `json:"total" expr:"> 0" code:"order.total.positive"`
+	Count   Qty       `json:"count,omitempty" expr:"Count % 2 == 0 && Count * 1.5 < 30"`
+	Status  Status    `json:"status" regexp:"^[A-Z]+$" expr:"Status == 'new' || Status.length > 3"`
+	Note    *string   `json:"note,omitempty" expr:"Note.length <= 10 && !(Note == 'x')"`
+	Rush    bool      `json:"rush" regexp:"^true$" severity:"warning"`
+	Placed  time.Time `json:"placed" expr:"Placed.getFullYear() > 2000"`
+	Code    string    `json:"code/x" expr:"Code.toUpperCase() == Code"`
+	PIN     string    `json:"pin" sensitive:"true" regexp:"^[0-9]{4}$"`
+	Skipped int       `json:"-" expr:"> 1"`
+	private int       `expr:"> 1"`
+}
+
+type Empty struct {
+	Name string `expr:"Name.indexOf('a') >= 0"`
+}
+
+type Renamed struct {
+	Qty  int    `yaml:"qty" check:"> 0"`
+	Name string `yaml:"-" check:"Name.length > 1"`
+}
+
+type Untagged struct {
+	Name string
+}
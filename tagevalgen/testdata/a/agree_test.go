@@ -0,0 +1,74 @@
+package a
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gdotgordon/tageval"
+)
+
+// plainOrder has the fields and tags of Order, without its generated
+// method, so the Validator evaluates every rule itself.
+type plainOrder Order
+
+// TestAgree checks that the generated method, if the golden file was
+// copied in beside this one, gives the results the Validator does.
+func TestAgree(t *testing.T) {
+	if _, ok := interface{}(&Order{}).(tageval.GeneratedValidator); !ok {
+		t.Skip("no generated method")
+	}
+	short, long, x := "short", "far too long", "x"
+	orders := []Order{
+		{},
+		{ID: "17", Total: 5.5, Count: 4, Status: "new", Note: &short,
+			Rush: true, Placed: time.Now(), Code: "AB", PIN: "1234"},
+		{ID: "x1", Total: -1, Count: 3, Status: "old", Note: &long,
+			Code: "ab", PIN: "12"},
+		{Count: 20, Status: "ready", Note: &x},
+		{Count: -2, Status: "NEW", Note: new(string)},
+	}
+	v, err := tageval.NewValidator(tageval.ShowSuccesses(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	type key struct {
+		name string
+		kind tageval.RuleKind
+		expr string
+	}
+	generated := func(o Order) []tageval.Result {
+		return interface{}(&o).(tageval.GeneratedValidator).TagevalValidate()
+	}
+	covered := make(map[key]bool)
+	for _, o := range orders {
+		for _, r := range generated(o) {
+			covered[key{r.Name, r.Kind, r.Expr}] = true
+		}
+	}
+
+	// The Validator's results for the rules the method covers must be
+	// those of the method, though not in the same order.
+	for _, o := range orders {
+		_, res, err := v.Validate(plainOrder(o))
+		if err != nil {
+			t.Fatalf("validation failed with error: %v", err)
+		}
+		want := make(map[key]tageval.Result)
+		for _, r := range res {
+			if k := (key{r.Name, r.Kind, r.Expr}); covered[k] {
+				want[k] = r
+			}
+		}
+		got := generated(o)
+		if len(got) != len(want) {
+			t.Fatalf("for %+v, generated %v, expected %v", o, got, want)
+		}
+		for _, g := range got {
+			w, ok := want[key{g.Name, g.Kind, g.Expr}]
+			if !ok || g.Path != w.Path || g.Valid != w.Valid ||
+				g.Severity != w.Severity || g.Redacted != w.Redacted {
+				t.Fatalf("for %+v, generated %+v, expected %+v", o, g, w)
+			}
+		}
+	}
+}
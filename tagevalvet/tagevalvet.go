@@ -0,0 +1,304 @@
+// Package tagevalvet defines an Analyzer that checks tageval struct tags,
+// so that errors in expressions and regexps are found at build time
+// rather than when a value is first validated.
+//
+// The analyzer reports:
+//   - JavaScript expressions in expr and transition tags that do not parse,
+//   - regular expressions that do not compile,
+//   - severity and code tags naming unknown rule kinds or severities,
+//     or missing a value,
+//   - expressions referring to identifiers other than the field name,
+//     variables they declare themselves, the JavaScript built-ins, or
+//     the now variable the Validator defines (and in transition tags,
+//     old and prev, the old value),
+//   - validation tags on unexported fields, which are ignored when
+//     validating under JSON rules (the default).
+//
+// It is run by the cmd/tagevalvet command, which may be used directly or
+// with go vet -vettool.
+package tagevalvet
+
+import (
+	"go/ast"
+	"go/token"
+	"reflect"
+	"regexp/syntax"
+	"strconv"
+	"strings"
+
+	"github.com/gdotgordon/tageval"
+	jsast "github.com/robertkrimen/otto/ast"
+	"github.com/robertkrimen/otto/parser"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+const doc = `check tageval struct tags
+
+The tagevalvet analyzer parses the JavaScript in expr and transition tags
+and compiles the regular expressions in regexp tags, and checks severity
+and code tags, reporting any errors, along with expressions that refer to
+unknown identifiers and tags on unexported fields that are ignored under
+JSON rules.`
+
+// Analyzer checks tageval struct tags.
+var Analyzer = &analysis.Analyzer{
+	Name:     "tagevalvet",
+	Doc:      doc,
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+// Flags matching the Validator options.
+var (
+	exprTag   = tageval.ExprTag
+	regexpTag = tageval.RegexpTag
+	asJSON    = true
+)
+
+func init() {
+	Analyzer.Flags.StringVar(&exprTag, "expr", exprTag,
+		"name of the struct tag holding JavaScript expressions")
+	Analyzer.Flags.StringVar(&regexpTag, "regexp", regexpTag,
+		"name of the struct tag holding regular expressions")
+	Analyzer.Flags.BoolVar(&asJSON, "asjson", asJSON,
+		"validation obeys JSON rules, so unexported fields are skipped")
+}
+
+// jsGlobals are the identifiers predefined in the JavaScript engine,
+// including now, defined by the Validator as the current time.
+var jsGlobals = map[string]bool{
+	"Array": true, "Boolean": true, "Date": true, "Error": true,
+	"EvalError": true, "Function": true, "Infinity": true, "JSON": true,
+	"Math": true, "NaN": true, "Number": true, "Object": true,
+	"RangeError": true, "ReferenceError": true, "RegExp": true,
+	"String": true, "SyntaxError": true, "TypeError": true, "URIError": true,
+	"arguments": true, "console": true, "decodeURI": true,
+	"decodeURIComponent": true, "encodeURI": true, "encodeURIComponent": true,
+	"escape": true, "eval": true, "isFinite": true, "isNaN": true, "now": true,
+	"parseFloat": true, "parseInt": true, "undefined": true, "unescape": true,
+}
+
+// transitionVars are the variables transition expressions may refer to,
+// besides those of expr tags.
+var transitionVars = map[string]bool{
+	tageval.OldVar: true, tageval.PrevVar: true,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	insp := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	insp.Preorder([]ast.Node{(*ast.StructType)(nil)},
+		func(n ast.Node) {
+			for _, field := range n.(*ast.StructType).Fields.List {
+				if field.Tag != nil {
+					checkField(pass, field)
+				}
+			}
+		})
+	return nil, nil
+}
+
+// checkField checks the tags of one field declaration, which may declare
+// several names (or none, if it is embedded).
+func checkField(pass *analysis.Pass, field *ast.Field) {
+	raw := field.Tag.Value
+	unquoted, err := strconv.Unquote(raw)
+	if err != nil {
+		return
+	}
+	tag := reflect.StructTag(unquoted)
+	if sev, ok := tag.Lookup(tageval.SeverityTag); ok {
+		if _, err := tageval.SeverityOf(tag, ""); err != nil {
+			pass.Reportf(valuePos(field.Tag, tagKey(tageval.SeverityTag), sev),
+				"invalid %s tag: %v", tageval.SeverityTag, err)
+		}
+	}
+	if code, ok := tag.Lookup(tageval.CodeTag); ok {
+		if _, err := tageval.CodeOf(tag, "", "", ""); err != nil {
+			pass.Reportf(valuePos(field.Tag, tagKey(tageval.CodeTag), code),
+				"invalid %s tag: %v", tageval.CodeTag, err)
+		}
+	}
+	expr, hasExpr := tag.Lookup(exprTag)
+	pattern, hasRegexp := tag.Lookup(regexpTag)
+	trans, hasTrans := tag.Lookup(tageval.TransitionTag)
+	if !hasExpr && !hasRegexp && !hasTrans {
+		return
+	}
+
+	var names []string
+	for _, id := range field.Names {
+		names = append(names, id.Name)
+	}
+	if len(names) == 0 {
+		// An embedded field is named after its type.
+		names = append(names, embeddedName(field.Type))
+	}
+
+	for _, name := range names {
+		if asJSON && !token.IsExported(name) {
+			pass.Reportf(field.Tag.Pos(),
+				"%s tag on unexported field %s is ignored under JSON rules",
+				tagsOf(hasExpr, hasRegexp, hasTrans), name)
+		}
+	}
+
+	if hasRegexp {
+		pos := valuePos(field.Tag, tagKey(regexpTag), pattern)
+		if _, err := syntax.Parse(pattern, syntax.Perl); err != nil {
+			pass.Reportf(pos, "invalid %s tag: %v", regexpTag, err)
+		}
+	}
+	if hasExpr {
+		pos := valuePos(field.Tag, tagKey(exprTag), expr)
+		for _, name := range names {
+			checkExpr(pass, pos, exprTag, name, expr, nil)
+		}
+	}
+	if hasTrans {
+		pos := valuePos(field.Tag, tagKey(tageval.TransitionTag), trans)
+		for _, name := range names {
+			checkExpr(pass, pos, tageval.TransitionTag, name, trans,
+				transitionVars)
+		}
+	}
+}
+
+// checkExpr parses the expression of the named tag for the field, and
+// checks the identifiers it refers to, which may include the vars.  The
+// position is that of the start of the expression, or of the whole tag
+// if that is unknown.
+func checkExpr(pass *analysis.Pass, pos token.Pos, tagName, name,
+	expr string, vars map[string]bool) {
+	full := tageval.ExpandShortcut(name, expr)
+
+	// Positions in a shortcut are relative to the original expression.
+	shift := len(full) - len(expr)
+	at := func(idx int) token.Pos {
+		if !pos.IsValid() || idx < shift {
+			return pos
+		}
+		return pos + token.Pos(idx-shift)
+	}
+
+	prog, err := parser.ParseFile(nil, "", full, 0)
+	if err != nil {
+		offset, msg := 0, err.Error()
+		if el, ok := err.(*parser.ErrorList); ok && len(*el) > 0 {
+			// Tags are on one line, and the offset is left unset
+			// without a file set, so the column gives the position.
+			offset, msg = (*el)[0].Position.Column-1, (*el)[0].Message
+		}
+		pass.Reportf(at(offset), "invalid %s tag for field %s: %s", tagName,
+			name, msg)
+		return
+	}
+
+	iv := &identVisitor{declared: make(map[string]bool)}
+	jsast.Walk(iv, prog)
+	for _, id := range iv.refs {
+		if id.Name == name || iv.declared[id.Name] || jsGlobals[id.Name] ||
+			vars[id.Name] {
+			continue
+		}
+		pass.Reportf(at(int(id.Idx)-1),
+			"%s tag for field %s refers to unknown identifier %s", tagName,
+			name, id.Name)
+	}
+}
+
+// An identVisitor collects the identifiers an expression declares and
+// those it refers to.  Scoping is ignored, so a name declared anywhere
+// counts as declared everywhere.
+type identVisitor struct {
+	declared map[string]bool
+	refs     []*jsast.Identifier
+}
+
+func (iv *identVisitor) Enter(n jsast.Node) jsast.Visitor {
+	switch n := n.(type) {
+	case *jsast.DotExpression:
+		// The property name is not a reference.
+		jsast.Walk(iv, n.Left)
+		return nil
+	case *jsast.LabelledStatement:
+		jsast.Walk(iv, n.Statement)
+		return nil
+	case *jsast.BranchStatement:
+		return nil
+	case *jsast.VariableExpression:
+		iv.declared[n.Name] = true
+	case *jsast.FunctionLiteral:
+		if n.Name != nil {
+			iv.declared[n.Name.Name] = true
+		}
+		for _, p := range n.ParameterList.List {
+			iv.declared[p.Name] = true
+		}
+	case *jsast.CatchStatement:
+		iv.declared[n.Parameter.Name] = true
+	case *jsast.Identifier:
+		// Optional names, as of anonymous functions, are walked as nil.
+		if n != nil {
+			iv.refs = append(iv.refs, n)
+		}
+	}
+	return iv
+}
+
+func (iv *identVisitor) Exit(n jsast.Node) {}
+
+// valuePos returns the position of the tag value within a raw string
+// literal, or that of the whole literal if the value can't be found
+// verbatim, as when it contains escapes.
+func valuePos(lit *ast.BasicLit, key, value string) token.Pos {
+	if !strings.HasPrefix(lit.Value, "`") {
+		return lit.Pos()
+	}
+	i := strings.Index(lit.Value, key+value+`"`)
+	if i < 0 {
+		return lit.Pos()
+	}
+	return lit.Pos() + token.Pos(i+len(key))
+}
+
+// tagKey returns the text introducing the value of the named tag.
+func tagKey(name string) string {
+	return name + `:"`
+}
+
+// embeddedName returns the field name of an embedded type.
+func embeddedName(t ast.Expr) string {
+	switch t := t.(type) {
+	case *ast.StarExpr:
+		return embeddedName(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel.Name
+	case *ast.IndexExpr:
+		return embeddedName(t.X)
+	case *ast.IndexListExpr:
+		return embeddedName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
+// tagsOf names the validation tags present.
+func tagsOf(hasExpr, hasRegexp, hasTrans bool) string {
+	var tags []string
+	for _, t := range []struct {
+		has  bool
+		name string
+	}{
+		{hasExpr, exprTag},
+		{hasRegexp, regexpTag},
+		{hasTrans, tageval.TransitionTag},
+	} {
+		if t.has {
+			tags = append(tags, t.name)
+		}
+	}
+	return strings.Join(tags, "/")
+}
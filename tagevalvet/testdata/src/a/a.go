@@ -0,0 +1,26 @@
+package a
+
+type Order struct {
+	ID     string  `json:"id" regexp:"^[0-9]+$"`
+	Total  float64 `json:"total" expr:"> 0"`
+	Count  int     `json:"count" expr:"Count > 0 && Count < Total"` // want `expr tag for field Count refers to unknown identifier Total`
+	Status string  `json:"status" expr:"Status == 'new' || (function(s) { var ok = s.length > 0; return ok; })(Status)"`
+	Note   string  `expr:"Math.max(Note.length, 1) < 100 && !isNaN(parseInt(Note))"`
+	Code   string  `regexp:"^[a-z+$"`           // want `invalid regexp tag: error parsing regexp: missing closing \]: .*`
+	Bad    int     `expr:"Bad >"`               // want `invalid expr tag for field Bad: .*`
+	Short  int     `expr:"> limit"`             // want `expr tag for field Short refers to unknown identifier limit`
+	secret string  `json:"-" regexp:"^[a-z]+$"` // want `regexp tag on unexported field secret is ignored under JSON rules`
+	Plain  string  `json:"plain"`
+	Soft   int     `expr:"> 0" severity:"warning"`
+	Mixed  int     `required:"true" severity:"info,required=error"`
+	Typo   int     `expr:"> 0" severity:"expr=warn"` // want `invalid severity tag: unknown severity "warn"`
+	Coded  int     `expr:"> 0" required:"true" code:"qty.positive,required=qty.missing"`
+	NoCode int     `expr:"> 0" code:"expr="`   // want `invalid code tag: missing value in "expr="`
+	BadKey int     `expr:"> 0" code:"exp=x.y"` // want `invalid code tag: unknown rule kind "exp"`
+	Expiry int64   `expr:"Expiry > now.getTime()"`
+	State  string  `transition:"old == State || prev == 'new' && State == 'paid'"`
+	Rev    int     `expr:"> old"`                    // want `expr tag for field Rev refers to unknown identifier old`
+	Seq    int     `transition:"> old && Seq < max"` // want `transition tag for field Seq refers to unknown identifier max`
+	Step   int     `transition:"Step >"`             // want `invalid transition tag for field Step: .*`
+	hidden int     `json:"-" transition:"> old"`     // want `transition tag on unexported field hidden is ignored under JSON rules`
+}
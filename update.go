@@ -0,0 +1,177 @@
+package tageval
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// TransitionTag holds an expression that must be true of a change to a
+// field, as checked by ValidateUpdate, with the new value bound to the
+// field's name, as for an expr tag, and the old value to "old", or its
+// synonym "prev" ("new" being reserved in JavaScript), as in
+//
+//	Status string `json:"status" transition:"old == Status || old == 'pending' && Status == 'active'"`
+//
+// Shortcuts work as they do for expr tags, so that
+//
+//	Version int `json:"version" transition:"> old"`
+//
+// requires the version to increase.
+const TransitionTag = "transition"
+
+// ImmutableTag marks a field that may not be changed by an update, as
+// checked by ValidateUpdate, as in
+//
+//	CreatedAt time.Time `json:"created_at" immutable:"true"`
+const ImmutableTag = "immutable"
+
+// The names the old value of a field is bound to in transition rules.
+const (
+	OldVar  = "old"
+	PrevVar = "prev"
+)
+
+// ValidateUpdate validates an update, replacing oldItem with newItem, as
+// for a PUT or PATCH request.  The new item is validated as Validate
+// does, and in addition the transition and immutable rules of its fields
+// are evaluated against the old item's values of the same fields.  Either
+// item may be a pointer.  The two are walked in parallel, and must be of
+// the same type throughout: an error is returned if, say, an interface
+// holds values of different types in each.  Parts of the new item with no
+// counterpart in the old, such as elements appended to a slice, new map
+// entries and pointers that were nil, are taken as created rather than
+// changed, so their transition and immutable rules are not evaluated.
+func (v Validator) ValidateUpdate(oldItem, newItem interface{}) (bool,
+	[]Result, error) {
+	ov, nv := reflect.ValueOf(oldItem), reflect.ValueOf(newItem)
+	for ov.Kind() == reflect.Ptr {
+		ov = ov.Elem()
+	}
+	for nv.Kind() == reflect.Ptr {
+		nv = nv.Elem()
+	}
+	if !ov.IsValid() || !nv.IsValid() {
+		return false, nil, fmt.Errorf("cannot validate update of nil item")
+	}
+	return v.runWalk(nv, &walk{safe: true, old: ov})
+}
+
+// processUpdate evaluates the transition and immutable rules of a field
+// of the struct type st, with its old value as well as its new one.
+func (v Validator) processUpdate(st reflect.Type, f reflect.StructField,
+	val, old reflect.Value, w *walk, path string) error {
+	transTag := v.rule(w.rules, st, f, TransitionRule)
+	immTag := v.rule(w.rules, st, f, ImmutableRule)
+	if transTag == "" && immTag == "" {
+		return nil
+	}
+
+	sev, err := parseSeverity(f.Tag.Get(SeverityTag))
+	if err != nil {
+		return fmt.Errorf("invalid %s tag for field '%s': %v",
+			SeverityTag, f.Name, err)
+	}
+	codes, err := parseCode(f.Tag.Get(CodeTag))
+	if err != nil {
+		return fmt.Errorf("invalid %s tag for field '%s': %v",
+			CodeTag, f.Name, err)
+	}
+
+	// Nil values are bound as such, as a change from or to nil is still
+	// a change.
+	iface, _, err := interfaceOf(val, w, f.Name)
+	if err != nil {
+		return err
+	}
+	prev, _, err := interfaceOf(old, w, f.Name)
+	if err != nil {
+		return err
+	}
+	shown, redacted := v.redact(w, f, iface)
+
+	if immTag != "" {
+		immutable, err := strconv.ParseBool(immTag)
+		if err != nil {
+			return fmt.Errorf("invalid %s tag for field '%s': %v",
+				ImmutableTag, f.Name, err)
+		}
+		if immutable {
+			start := time.Now()
+			bv := sameValue(prev, iface)
+			r := Result{
+				Name:     f.Name,
+				Path:     path,
+				Value:    shown,
+				Type:     f.Type,
+				Kind:     ImmutableRule,
+				Expr:     ImmutableTag,
+				Valid:    bv,
+				Redacted: redacted,
+				Severity: sev.of(ImmutableRule),
+				Code:     ruleCode(codes, st, f, ImmutableRule),
+			}
+			v.evaluated(st, r, time.Since(start), false)
+			if !bv || v.showSuccesses {
+				w.res = append(w.res, r)
+			}
+		}
+	}
+
+	if transTag != "" {
+		expr := ExpandShortcut(f.Name, transTag)
+		start := time.Now()
+		bv, err := v.eval.evalTransition(f.Name, prev, iface, expr)
+		if err != nil {
+			return err
+		}
+		r := Result{
+			Name:     f.Name,
+			Path:     path,
+			Value:    shown,
+			Type:     f.Type,
+			Kind:     TransitionRule,
+			Expr:     expr,
+			Valid:    bv,
+			Redacted: redacted,
+			Severity: sev.of(TransitionRule),
+			Code:     ruleCode(codes, st, f, TransitionRule),
+		}
+		v.evaluated(st, r, time.Since(start), false)
+		if !bv || v.showSuccesses {
+			w.res = append(w.res, r)
+		}
+	}
+	return nil
+}
+
+// sameValue reports whether the old and new values of a field are the
+// same, taking times at the same instant as the same.
+func sameValue(old, val interface{}) bool {
+	if ot, ok := old.(time.Time); ok {
+		if t, ok := val.(time.Time); ok {
+			return ot.Equal(t)
+		}
+	}
+	return reflect.DeepEqual(old, val)
+}
+
+// evalTransition evaluates a transition rule, binding the old value as
+// well as the new one.  The old value is unbound afterwards, so that
+// other expressions can't see it.
+func (e *evaluator) evalTransition(name string, old, val interface{},
+	expr string) (bool, error) {
+	e.reset()
+	defer func() {
+		for _, v := range []string{OldVar, PrevVar} {
+			e.run("delete " + v)
+		}
+	}()
+	for _, v := range []string{OldVar, PrevVar} {
+		if _, err := e.bind(v, old); err != nil {
+			return false, err
+		}
+	}
+	return e.evalBound(name, val, expr)
+}
@@ -0,0 +1,114 @@
+package tageval
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type Ticket struct {
+	ID        string    `json:"id" immutable:"true"`
+	Status    string    `json:"status" transition:"old == Status || old == 'open' && Status == 'closed'"`
+	Version   int       `json:"version" expr:"> 0" transition:"> prev"`
+	CreatedAt time.Time `json:"created_at" immutable:"true"`
+	Notes     []Note    `json:"notes"`
+	Extra     interface{}
+}
+
+type Note struct {
+	Text   string  `json:"text" immutable:"true" severity:"warning"`
+	Author *string `json:"author" immutable:"true"`
+}
+
+func TestValidateUpdate(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ann := "ann"
+	old := Ticket{ID: "t1", Status: "open", Version: 1, CreatedAt: created,
+		Notes: []Note{{Text: "first", Author: &ann}}}
+
+	v, _ := NewValidator(ShowSuccesses(true))
+	upd := old
+	upd.Status, upd.Version = "closed", 2
+	upd.CreatedAt = created.In(time.FixedZone("X", 3600))
+	upd.Notes = []Note{{Text: "first", Author: &ann}, {Text: "second"}}
+	ok, res, err := v.ValidateUpdate(old, &upd)
+	if err != nil {
+		t.Fatalf("validation failed with error: %v", err)
+	}
+	PrintResults(os.Stdout, res)
+	if !ok {
+		t.Fatalf("unexpected failure")
+	}
+
+	// The appended note was created, so has no rules to keep.
+	correlate(t, res, []checker{{"ID", true}, {"Status", true},
+		{"Version", true}, {"Version", true}, {"CreatedAt", true},
+		{"Text", true}, {"Author", true}})
+	if res[3].Kind != TransitionRule || res[3].Expr != "Version > prev" ||
+		res[3].Code != "Ticket.Version.transition" ||
+		res[4].Kind != ImmutableRule {
+		t.Fatalf("unexpected results %v", res)
+	}
+
+	v, _ = NewValidator()
+	bob := "bob"
+	upd = old
+	upd.ID, upd.Status, upd.Version = "t2", "reopened", 0
+	upd.Notes = []Note{{Text: "edited", Author: &bob}}
+	ok, res, err = v.ValidateUpdate(&old, &upd)
+	if err != nil {
+		t.Fatalf("validation failed with error: %v", err)
+	}
+	PrintResults(os.Stdout, res)
+	if ok {
+		t.Fatalf("unexpected success")
+	}
+	correlate(t, res, []checker{{"ID", false}, {"Status", false},
+		{"Version", false}, {"Version", false}, {"Text", false},
+		{"Author", false}})
+	if res[4].Severity != SeverityWarning || res[4].Path != "/notes/0/text" {
+		t.Fatalf("unexpected result %v", res[4])
+	}
+
+	// The rules can be added like any other.
+	if err := v.AddRule(nil, "Status", TransitionRule, "true"); err == nil {
+		t.Fatalf("did not get expected error for nil type")
+	}
+	v.AddRule(reflect.TypeOf(Ticket{}), "Status", TransitionRule, "")
+	v.AddRule(reflect.TypeOf(Ticket{}), "ID", ImmutableRule, "false")
+	_, res, _ = v.ValidateUpdate(old, upd)
+	correlate(t, res, []checker{{"Version", false}, {"Version", false},
+		{"Text", false}, {"Author", false}})
+
+	// The values must have the same shape.
+	upd = old
+	old.Extra, upd.Extra = 1, "one"
+	if _, _, err := v.ValidateUpdate(old, upd); err == nil {
+		t.Fatalf("did not get expected error for mismatched structure")
+	}
+	if _, _, err := v.ValidateUpdate(old, Note{}); err == nil {
+		t.Fatalf("did not get expected error for mismatched types")
+	}
+	if _, _, err := v.ValidateUpdate((*Ticket)(nil), upd); err == nil {
+		t.Fatalf("did not get expected error for nil")
+	}
+}
+
+type Draft struct {
+	Title string `expr:"typeof old === 'undefined'" transition:"old != Title"`
+}
+
+func TestValidateAfterUpdate(t *testing.T) {
+	v, _ := NewValidator()
+	ok, res, err := v.ValidateUpdate(Draft{"a"}, Draft{"b"})
+	if err != nil || !ok {
+		t.Fatalf("unexpected failure %v: %v", res, err)
+	}
+
+	// The old value is not left for later expressions.
+	ok, res, err = v.Validate(Draft{"b"})
+	if err != nil || !ok {
+		t.Fatalf("unexpected failure %v: %v", res, err)
+	}
+}
@@ -2,19 +2,23 @@ package tageval
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
-	"os"
+	"log/slog"
 	"reflect"
 	"strconv"
 	"strings"
 	"time"
 	"unicode"
 	"unsafe"
+
+	"golang.org/x/text/language"
 )
 
-// Struct tag names for the types of validation that can be done.
-// Note a JSON tag may or may not be present.
+// Default struct tag names for the types of validation that can be done.
+// Note a JSON tag may or may not be present.  The names may be changed
+// per Validator with the ExprTagName and RegexpTagName options.
 // Example struct members
 //   LastName string `json:"last_name" expr:"LastName.length<10"`
 //   LastName string `expr:"LastName.length<10"`
@@ -28,31 +32,147 @@ const (
 	RegexpTag = "regexp"
 )
 
+// RequiredTag marks a field that must be present, as in
+//   Name string `json:"name" required:"true"`
+// For Validate, present means the field is not the zero value for
+// its type.  For ValidateJSON, it means the property appeared in the
+// decoded document, so that zero values may be supplied explicitly.
+const RequiredTag = "required"
+
+// DocTag holds a description of the field, for generated schemas, as in
+//   Total int `json:"total" doc:"Order total in cents" expr:"> 0"`
+const DocTag = "doc"
+
+// SensitiveTag marks a field whose value must never be revealed, such
+// as a password or an access token, as in
+//   Password string `json:"password" sensitive:"true" expr:"Password.length >= 12"`
+// The field is validated as usual, but its value is reported as Redacted
+// (or as a Redactor says) in Results, and so in their String form, in
+// reports and in the log.  RedactTag is a synonym.  To fail safe, any
+// value other than one strconv.ParseBool takes as false counts as true.
+const (
+	SensitiveTag = "sensitive"
+	RedactTag    = "redact"
+)
+
+// Redacted is the value reported in place of that of a sensitive field.
+const Redacted = "[REDACTED]"
+
+// SeverityTag sets the Severity of a field's rules, for all of them, as in
+//   Nickname string `json:"nickname" expr:"Nickname.length < 20" severity:"warning"`
+// or by kind, with a comma separated list that may also give a default:
+//   SKU string `regexp:"^[A-Z]{3}-[0-9]+$" expr:"SKU.length < 12" severity:"regexp=warning"`
+//   SKU string `regexp:"^[A-Z]{3}-[0-9]+$" required:"true" severity:"info,required=error"`
+const SeverityTag = "severity"
+
+// CodeTag gives the rules of a field stable identifiers, reported as the
+// Code of their Results, so that clients can tell failures apart, map
+// them to messages and count them without depending on the text of the
+// rules.  As with the severity tag, a code may be given for all of the
+// field's rules, or by kind:
+//   Total int `json:"total" expr:"> 0" code:"order.total.positive"`
+//   SKU string `regexp:"^[A-Z]{3}$" required:"true" code:"sku.format,required=sku.missing"`
+// Rules without a code are given one made up of the names of the struct
+// type, the field and the rule kind, as in "Order.Total.expr".  Problems
+// decoding a field's value in ValidateJSON have the decode kind.
+const CodeTag = "code"
+
+// A Severity says how much a failed rule matters.  Only a failed rule of
+// SeverityError makes the validation fail as a whole, so that new rules
+// can be rolled out as warnings, and their failures monitored, before
+// they are enforced.
+type Severity string
+
+// The Severities, from most to least severe.  An empty Severity is taken
+// as SeverityError.
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+)
+
+// RuleKind identifies the kind of rule that produced a Result.
+type RuleKind string
+
+// The kinds of rules reported in a Result.  DecodeRule covers the
+// problems found while decoding JSON in ValidateJSON, such as unknown
+// properties or type mismatches.  TransitionRule and ImmutableRule are
+// only evaluated by ValidateUpdate.
+const (
+	ExprRule       RuleKind = "expr"
+	RegexpRule     RuleKind = "regexp"
+	RequiredRule   RuleKind = "required"
+	DecodeRule     RuleKind = "decode"
+	TransitionRule RuleKind = "transition"
+	ImmutableRule  RuleKind = "immutable"
+)
+
 // The Validator traverses a given interface{} instance to
 // locate our custom tags as well as JSON tags.  It will
 // validate any fields that contain validation expressions,
 // either JavaScript expressions or regexps, and report back
 // The results of the validation.
 type Validator struct {
-	asJSON        bool
+	conv          Convention
 	showSuccesses bool
+	exprTag       string
+	regexpTag     string
+	redactor      Redactor
+	catalog       *Catalog
+	langs         []language.Tag
+	clock         func() time.Time
+	random        func() float64
+	sandbox       *SandboxConfig
+	log           *slog.Logger
+	observers     []Observer
+	rules         *externalRules
 	eval          *evaluator
 }
 
 // A Result captures the data from a single evaluation.  The validation
 // returns a list of failed (and optionally successful) validations
 // containing the following information.
+//
+// Path is the JSON pointer (RFC 6901) to the value, built from the
+// serialized field names when a Convention is in effect, or the Go
+// field names otherwise.
+//
+// Value is the value of the field, unless Redacted is set, in which case
+// it is a masked stand-in for a sensitive one.  Severity is that of the
+// rule, as given by the severity tag, and Code its stable identifier, as
+// given by the code tag.  Message is the localized message for a failed
+// rule, from the Validator's Catalog, if it has one.
 type Result struct {
-	Name  string
-	Value interface{}
-	Type  reflect.Type
-	Expr  string
-	Valid bool
+	Name     string
+	Path     string
+	Value    interface{}
+	Type     reflect.Type
+	Kind     RuleKind
+	Expr     string
+	Valid    bool
+	Redacted bool
+	Severity Severity
+	Code     string
+	Message  string
+}
+
+// Fails reports whether the result makes validation fail, that is, the
+// rule failed and is of SeverityError.
+func (res Result) Fails() bool {
+	return !res.Valid && (res.Severity == SeverityError || res.Severity == "")
 }
 
 // Option defines funcs for passing Validator configuration options.
 type Option func(*Validator)
 
+// A Redactor decides how the value of a field is reported in a Result.
+// It returns the value to report in its place and true to mask it, or
+// false to leave it be.  It is called for every field reported on, so
+// that it can mask values by name, type or content as well as by tag.
+// A field with the sensitive tag is masked as Redacted if the Redactor
+// leaves it be.
+type Redactor func(f reflect.StructField, val interface{}) (interface{}, bool)
+
 // TypeMapper delcares the signature of the function to add a
 // custom type mapping.  Essentially, the string returned is a
 // JavaScript fragment that creates an object that is somehwat
@@ -81,11 +201,7 @@ var (
 	}
 )
 
-var (
-	lg = newLogger(os.Stderr, logOff)
-
-	timeType = reflect.TypeOf(time.Now())
-)
+var timeType = reflect.TypeOf(time.Now())
 
 func init() {
 	mappers = make(map[reflect.Type]TypeMapper)
@@ -96,8 +212,12 @@ func init() {
 // inspecting any item (interface{}).
 func NewValidator(options ...Option) (*Validator, error) {
 	val := Validator{
-		asJSON:        true,
+		conv:          JSONConvention,
 		showSuccesses: false,
+		exprTag:       ExprTag,
+		regexpTag:     RegexpTag,
+		log:           discardLogger,
+		rules:         &externalRules{},
 		eval:          newEvaluator(),
 	}
 	for _, opt := range options {
@@ -106,16 +226,46 @@ func NewValidator(options ...Option) (*Validator, error) {
 	for k, f := range mappers {
 		val.eval.addTypeMapping(k, f)
 	}
+
+	// The clock must be set before the sandbox freezes Date and Math.
+	if err := val.eval.environ(val.clock, val.random); err != nil {
+		return nil, err
+	}
+	if val.eval.console != nil {
+		if err := val.eval.installConsole(val.eval.vm); err != nil {
+			return nil, err
+		}
+	}
+	if val.sandbox != nil {
+		if err := val.eval.sandbox(*val.sandbox); err != nil {
+			return nil, err
+		}
+	}
 	return &val, nil
 }
 
 // Option functions for configuring Validator.
 
-// ProcessAsJSON tells the scanner to obey JSON serialization
-// rules when processing the various struct fields.
+// AsJSON tells the scanner to obey JSON serialization
+// rules when processing the various struct fields.  Passing
+// false disables serialization rules altogether, so that
+// every field is validated.
 func AsJSON(asJSON bool) Option {
 	return func(v *Validator) {
-		v.asJSON = asJSON
+		if asJSON {
+			v.conv = JSONConvention
+		} else {
+			v.conv = nil
+		}
+	}
+}
+
+// WithConvention tells the scanner to obey the serialization rules
+// of the supplied Convention, for example XMLConvention, instead of
+// the JSON ones.  A nil Convention is the same as AsJSON(false).
+func WithConvention(conv Convention) Option {
+	return func(v *Validator) {
+		v.conv = conv
 	}
 }
 
@@ -125,6 +275,41 @@ func ShowSuccesses(showSuccesses bool) Option {
 	}
 }
 
+// ExprTagName sets the name of the struct tag holding JavaScript
+// expressions, in place of the default "expr".  This is useful when
+// another library already claims the tag.
+func ExprTagName(name string) Option {
+	return func(v *Validator) {
+		v.exprTag = name
+	}
+}
+
+// RegexpTagName sets the name of the struct tag holding regular
+// expressions, in place of the default "regexp".
+func RegexpTagName(name string) Option {
+	return func(v *Validator) {
+		v.regexpTag = name
+	}
+}
+
+// WithRedactor sets a Redactor, for masking values beyond those of
+// fields with the sensitive tag, or masking them in some other way, such
+// as showing only the last four digits of a card number.
+func WithRedactor(r Redactor) Option {
+	return func(v *Validator) {
+		v.redactor = r
+	}
+}
+
+// WithCatalog sets the Catalog of messages for failed Results, in the
+// languages given by Localized.  Without a language, the Catalog's
+// fallback languages are used.
+func WithCatalog(c *Catalog) Option {
+	return func(v *Validator) {
+		v.catalog = c
+	}
+}
+
 // AddTypeMapping allows the user to declare and add their
 // own type mapping to be used by the js engine.  The type
 // mapping function is explained in the TypeMapper type
@@ -138,7 +323,20 @@ func (v Validator) AddTypeMapping(t reflect.Type, tm TypeMapper) {
 // concurrency in the underlying Javascript engine.  Note the caches
 // of compiled expressions and regexps are not copied.
 func (v Validator) Copy() *Validator {
-	return &Validator{v.asJSON, v.showSuccesses, v.eval.copy()}
+	cv := v
+	cv.eval = v.eval.copy()
+	return &cv
+}
+
+// Localized returns a Validator whose failed Results carry messages from
+// its Catalog in the first of the languages that has one, such as those
+// of a request's Accept-Language header.  Unlike Copy, the Validator
+// shares the engine and caches of v, so the two must not be used
+// concurrently.
+func (v Validator) Localized(langs ...language.Tag) *Validator {
+	lv := v
+	lv.langs = langs
+	return &lv
 }
 
 // Validate a Go item (or pointer) of any kind.  If the item is not
@@ -171,41 +369,103 @@ func (v Validator) ValidateAddressable(itemAddr interface{}) (bool,
 
 func (v Validator) doValidation(rv reflect.Value, safe bool) (
 	bool, []Result, error) {
+	return v.runWalk(rv, &walk{safe: safe})
+}
+
+// A walk holds the state of a single validation pass.  When validating
+// decoded JSON, present holds the JSON pointers of every value that
+// appeared in the document.  When validating an update, old is the part
+// of the old value corresponding to the value being traversed, if it has
+// one.  The rules are those added to the Validator when the walk began.
+// The hidden count is the number of sensitive fields the walk is within.
+// An apply walk sets defaults and normalizes values, rather than
+// validating.
+type walk struct {
+	safe    bool
+	apply   bool
+	rules   *RuleSet
+	present map[string]bool
+	old     reflect.Value
+	hidden  int
+	res     []Result
+}
+
+// maskNested masks the results from the nth on, those found within the
+// value of the field, if the field is sensitive.  This is for results
+// made without the walk knowing it was within the field.
+func (w *walk) maskNested(n int, f reflect.StructField) {
+	if !IsSensitive(f.Tag) {
+		return
+	}
+	for i := n; i < len(w.res); i++ {
+		if !w.res[i].Redacted {
+			w.res[i].Value, w.res[i].Redacted = Redacted, true
+		}
+	}
+}
 
-	var res []Result
-	if err := v.traverse(rv, safe, &res); err != nil {
+func (v Validator) runWalk(rv reflect.Value, w *walk) (bool, []Result, error) {
+	start := time.Now()
+	w.rules = v.currentRules()
+	if err := v.traverse(rv, w, ""); err != nil {
+		for _, o := range v.observers {
+			o.OnError(err)
+		}
 		return false, nil, err
 	}
 	ok := true
-	for _, rslt := range res {
-		if !rslt.Valid {
+	for i, rslt := range w.res {
+		if rslt.Fails() {
 			ok = false
-			break
+		}
+		if !rslt.Valid && v.catalog != nil {
+			w.res[i].Message = v.catalog.Message(rslt, v.langs...)
 		}
 	}
-	return ok, res, nil
+	if v.logging(slog.LevelDebug) {
+		v.log.Debug("validated", slog.String("type", rv.Type().String()),
+			slog.Bool("valid", ok), slog.Int("results", len(w.res)),
+			slog.Duration("duration", time.Since(start)))
+	}
+	return ok, w.res, nil
 }
 
 // The main processing loop is invoked recursively as we
 // traverse the value, eventually landing on a struct type,
 // which is where the tags are found.  Types such as built-ins
 // and channels require no further processing, so no action happens.
-func (v Validator) traverse(val reflect.Value, safe bool,
-	res *[]Result) error {
+// The path is the JSON pointer to the value being traversed.
+func (v Validator) traverse(val reflect.Value, w *walk, path string) error {
 	var err error
 	t := val.Type()
 
+	// When validating an update, the old value must have the same shape.
+	old := w.old
+	if old.IsValid() && old.Type() != t {
+		return fmt.Errorf("mismatched structure at '%s': old value is %v, "+
+			"new value is %v", path, old.Type(), t)
+	}
+
 	if t == timeType {
 		return nil
 	}
 
-	lg.trace("Incoming: %v, %v\n", t, t.Kind())
+	if v.logging(LevelTrace) {
+		v.log.Log(context.Background(), LevelTrace, "traverse",
+			slog.String("type", t.String()),
+			slog.String("kind", t.Kind().String()), slog.String("path", path))
+	}
 	switch t.Kind() {
 
 	// For slice and array, traverse each entry individually.
 	case reflect.Slice, reflect.Array:
 		for i := 0; i < val.Len(); i++ {
-			if err = v.traverse(val.Index(i), safe, res); err != nil {
+			ipath := pointerTo(path, strconv.Itoa(i))
+			var oi reflect.Value
+			if old.IsValid() && i < old.Len() {
+				oi = old.Index(i)
+			}
+			if err = v.descend(val.Index(i), oi, w, ipath); err != nil {
 				return err
 			}
 		}
@@ -214,7 +474,11 @@ func (v Validator) traverse(val reflect.Value, safe bool,
 	case reflect.Ptr:
 		rv := reflect.Indirect(val)
 		if rv.Kind() != reflect.Invalid {
-			if err = v.traverse(reflect.Indirect(val), safe, res); err != nil {
+			var orv reflect.Value
+			if old.IsValid() {
+				orv = reflect.Indirect(old)
+			}
+			if err = v.descend(rv, orv, w, path); err != nil {
 				return err
 			}
 		}
@@ -223,10 +487,15 @@ func (v Validator) traverse(val reflect.Value, safe bool,
 	case reflect.Map:
 		keys := val.MapKeys()
 		for _, key := range keys {
-			if err = v.traverse(key, safe, res); err != nil {
+			kpath := pointerTo(path, fmt.Sprint(key))
+			if err = v.descend(key, reflect.Value{}, w, kpath); err != nil {
 				return err
 			}
-			if err = v.traverse(val.MapIndex(key), safe, res); err != nil {
+			var ov reflect.Value
+			if old.IsValid() {
+				ov = old.MapIndex(key)
+			}
+			if err = v.descend(val.MapIndex(key), ov, w, kpath); err != nil {
 				return err
 			}
 		}
@@ -235,20 +504,33 @@ func (v Validator) traverse(val reflect.Value, safe bool,
 	// as this may be a type that has tagged fields.
 	case reflect.Interface:
 		if val.IsValid() && !val.IsNil() {
-			if err = v.traverse(val.Elem(), safe, res); err != nil {
+			var oe reflect.Value
+			if old.IsValid() {
+				oe = old.Elem()
+			}
+			if err = v.descend(val.Elem(), oe, w, path); err != nil {
 				return err
 			}
 		}
 
-	// All tags are found on struct fields.
+	// All tags are found on struct fields.  A generated validation
+	// method, if any, supplies the results of the rules it covers.
 	case reflect.Struct:
+		var gen []Result
+		if !w.apply {
+			for _, o := range v.observers {
+				o.OnStructEnter(t, path)
+			}
+			gen = generatedResults(val)
+		}
 		for i := 0; i < t.NumField(); i++ {
 			f := t.Field(i)
 
-			// If following JSON serialization rules, skip
+			// If following serialization rules, skip
 			// any private fields.
 			handleTag := true
-			if v.asJSON {
+			fi := FieldInfo{Name: f.Name}
+			if v.conv != nil {
 				var first rune
 				for _, c := range f.Name {
 					first = c
@@ -257,53 +539,256 @@ func (v Validator) traverse(val reflect.Value, safe bool,
 				if !unicode.IsUpper(first) {
 					handleTag = false
 				}
+				fi = v.conv.Field(f)
 			}
 
-			if handleTag {
-				if err = v.processTag(f, val.Field(i), safe, res); err != nil {
-					return err
+			// Inlined (embedded) structs don't add a level to the path.
+			fpath := path
+			if !fi.Inline {
+				fpath = pointerTo(path, fi.Name)
+			}
+
+			var of reflect.Value
+			if old.IsValid() {
+				of = old.Field(i)
+			}
+			if w.apply {
+				if handleTag {
+					err = v.applyTags(f, fi, val.Field(i), w)
+				}
+			} else if handleTag {
+				err = v.processTag(t, f, fi, val.Field(i), w, gen, path,
+					fpath)
+				if err == nil && of.IsValid() && !fi.Skip {
+					err = v.processUpdate(t, f, val.Field(i), of, w, fpath)
 				}
+			} else if v.hasRules(w.rules, t, f) {
+				v.skipped(t, f, fpath, SkipPrivate)
+			}
+			if err != nil {
+				return err
 			}
 
-			if err = v.traverse(val.Field(i), safe, res); err != nil {
+			// Whatever is within a sensitive field is sensitive too.
+			sensitive := IsSensitive(f.Tag)
+			if sensitive {
+				w.hidden++
+			}
+			if err = v.descend(val.Field(i), of, w, fpath); err != nil {
 				return err
 			}
+			if sensitive {
+				w.hidden--
+			}
 		}
 	}
 	return nil
 }
 
+// descend traverses a part of a value, along with the corresponding part
+// of the old value when validating an update, which is invalid if there
+// is none, as for an element appended to a slice.
+func (v Validator) descend(val, old reflect.Value, w *walk,
+	path string) error {
+	saved := w.old
+	w.old = old
+	err := v.traverse(val, w, path)
+	w.old = saved
+	return err
+}
+
 // Check the tags to see if there is something we need to validate.
 // Validation can also only occur if our custom tags are present,
-// although the json tag need not be present.
-func (v Validator) processTag(f reflect.StructField,
-	val reflect.Value, safe bool, res *[]Result) error {
+// although the json tag need not be present.  The gen results are
+// those of the enclosing struct's generated method.  The struct type is
+// st, its path the parent, and path that of the field itself.
+func (v Validator) processTag(st reflect.Type, f reflect.StructField,
+	fi FieldInfo, val reflect.Value, w *walk, gen []Result, parent,
+	path string) error {
 
-	// Our expression eval tags.
-	exprTag := f.Tag.Get("expr")
-	regexpTag := f.Tag.Get("regexp")
+	// Our expression eval tags, or the rules added in their place.
+	exprTag := v.rule(w.rules, st, f, ExprRule)
+	regexpTag := v.rule(w.rules, st, f, RegexpRule)
+	reqTag := v.rule(w.rules, st, f, RequiredRule)
+	if !v.hasRules(w.rules, st, f) {
+		return nil
+	}
+
+	if fi.Skip {
+		// This one won't get serialized, so skip.
+		v.skipped(st, f, path, SkipSerialization)
+		return nil
+	}
+
+	sev, err := parseSeverity(f.Tag.Get(SeverityTag))
+	if err != nil {
+		return fmt.Errorf("invalid %s tag for field '%s': %v",
+			SeverityTag, f.Name, err)
+	}
+	codes, err := parseCode(f.Tag.Get(CodeTag))
+	if err != nil {
+		return fmt.Errorf("invalid %s tag for field '%s': %v",
+			CodeTag, f.Name, err)
+	}
+
+	if reqTag != "" {
+		required, err := strconv.ParseBool(reqTag)
+		if err != nil {
+			return fmt.Errorf("invalid %s tag for field '%s': %v",
+				RequiredTag, f.Name, err)
+		}
+
+		// In a decoded document, only a property of an object that
+		// was itself present can be missing.
+		var missing bool
+		if w.present != nil {
+			missing = w.present[parent] && !w.present[path]
+		} else {
+			missing = val.IsZero()
+		}
+		if required {
+			r := Result{
+				Name:     f.Name,
+				Path:     path,
+				Type:     f.Type,
+				Kind:     RequiredRule,
+				Expr:     RequiredTag,
+				Valid:    !missing,
+				Severity: sev.of(RequiredRule),
+				Code:     ruleCode(codes, st, f, RequiredRule),
+			}
+			if !missing && val.CanInterface() {
+				r.Value, r.Redacted = v.redact(w, f, val.Interface())
+			}
+			v.evaluated(st, r, 0, false)
+			if missing || v.showSuccesses {
+				w.res = append(w.res, r)
+			}
+		}
+		if required && missing {
+			if exprTag != "" || regexpTag != "" {
+				v.skipped(st, f, path, SkipMissing)
+			}
+			return nil
+		}
+	}
 	if exprTag == "" && regexpTag == "" {
 		return nil
 	}
 
-	jtag, _ := f.Tag.Lookup("json")
-	if v.asJSON && jtag == "-" {
-		// This one won't get serialized to JSON, so skip.
+	iface, ok, err := interfaceOf(val, w, f.Name)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		v.skipped(st, f, path, SkipNil)
 		return nil
 	}
 
-	lg.trace("Process tag, name: %s type: %v kind: %v\n",
-		f.Name, f.Type.Name(), f.Type.Kind())
+	// Only the shown value may be reported or logged.
+	shown, redacted := v.redact(w, f, iface)
 
+	// Check whether this is the zero value for the type.  If
+	// we are obeying serialization rules, this won't be processed.
+	// Note: references (not pointers) to structs are serialized
+	// to JSON in Go even if they are empty.
+	if v.conv != nil && f.Type.Kind() != reflect.Struct {
+		if fi.OmitEmpty {
+			isZero := reflect.DeepEqual(iface,
+				reflect.Zero(reflect.TypeOf(iface)).Interface())
+			if isZero {
+				v.skipped(st, f, path, SkipOmitEmpty)
+				return nil
+			}
+		}
+	}
+
+	// Game on!  Let's validate.
+	var bv bool
+	if exprTag != "" {
+
+		// Generated code knows nothing of custom type mappings.
+		expr := ExpandShortcut(f.Name, exprTag)
+		_, mapped := v.eval.mapping[reflect.TypeOf(iface)]
+		start := time.Now()
+		r, generated := findGenerated(gen, f.Name, ExprRule, expr)
+		if generated && !mapped {
+			bv = r.Valid
+		} else {
+			generated = false
+			bv, err = v.eval.evalBoolExpr(f.Name, iface, expr)
+			if err != nil {
+				return err
+			}
+		}
+		d := time.Since(start)
+
+		r = Result{
+			Name:     f.Name,
+			Path:     path,
+			Value:    shown,
+			Type:     f.Type,
+			Kind:     ExprRule,
+			Expr:     expr,
+			Valid:    bv,
+			Redacted: redacted,
+			Severity: sev.of(ExprRule),
+			Code:     ruleCode(codes, st, f, ExprRule),
+		}
+		v.evaluated(st, r, d, generated)
+		if !bv || v.showSuccesses {
+			w.res = append(w.res, r)
+		}
+	}
+
+	if regexpTag != "" {
+		start := time.Now()
+		r, generated := findGenerated(gen, f.Name, RegexpRule, regexpTag)
+		if generated {
+			bv = r.Valid
+		} else {
+			str := v.iToStr(iface)
+			bv, err = v.eval.evalRegexp(str, regexpTag)
+			if err != nil {
+				return err
+			}
+		}
+		d := time.Since(start)
+
+		r = Result{
+			Name:     f.Name,
+			Path:     path,
+			Value:    shown,
+			Type:     f.Type,
+			Kind:     RegexpRule,
+			Expr:     regexpTag,
+			Valid:    bv,
+			Redacted: redacted,
+			Severity: sev.of(RegexpRule),
+			Code:     ruleCode(codes, st, f, RegexpRule),
+		}
+		v.evaluated(st, r, d, generated)
+		if !bv || v.showSuccesses {
+			w.res = append(w.res, r)
+		}
+	}
+	return nil
+}
+
+// interfaceOf returns the underlying or concrete value of the field with
+// the name, reaching private fields through unsafe if the walk allows,
+// or false if it is nil.
+func interfaceOf(val reflect.Value, w *walk, name string) (interface{},
+	bool, error) {
 	// Get the underlying or concrete value.
 	switch val.Kind() {
 	case reflect.Ptr, reflect.Interface:
 		val = val.Elem()
 	}
 
-	// If the value is something like a nil interface concrete object, skip.
+	// The value may be something like a nil interface concrete object.
 	if !val.IsValid() {
-		return nil
+		return nil, false, nil
 	}
 
 	var iface interface{}
@@ -327,7 +812,7 @@ func (v Validator) processTag(f reflect.StructField,
 		case reflect.Interface, reflect.Ptr:
 			for {
 				if !val.IsValid() || val.IsNil() {
-					return nil
+					return nil, false, nil
 				}
 				val = val.Elem()
 				if val.Kind() != reflect.Interface &&
@@ -337,10 +822,10 @@ func (v Validator) processTag(f reflect.StructField,
 			}
 			fallthrough
 		default:
-			if safe || !val.CanAddr() {
+			if w.safe || !val.CanAddr() {
 				// Even in non-safe mode, an interface may not work.
-				return fmt.Errorf("cannot access private field: '%s'",
-					f.Name)
+				return nil, false, fmt.Errorf(
+					"cannot access private field: '%s'", name)
 			}
 
 			// Been beat up and battered 'round
@@ -352,85 +837,223 @@ func (v Validator) processTag(f reflect.StructField,
 			iface = rf.Interface()
 		}
 	}
+	return iface, true, nil
+}
 
-	// Check whether this is the zero value for the type.  If
-	// We are serializing to JSON, this is won't be processed.
-	// Note: references (not pointers) to structs are serialized
-	// to JSON in Go even if they are empty.
-	if v.asJSON && f.Type.Kind() != reflect.Struct {
-		if strings.HasSuffix(jtag, ",omitempty") {
-			isZero := reflect.DeepEqual(iface,
-				reflect.Zero(reflect.TypeOf(iface)).Interface())
-			if isZero {
-				lg.info("Skip zero value for %s, '%v'\n", f.Name, iface)
-				return nil
-			}
+// hasRules reports whether the field of struct type st has any rules to
+// evaluate, given the added rules rs.
+func (v Validator) hasRules(rs *RuleSet, st reflect.Type,
+	f reflect.StructField) bool {
+	for _, kind := range ruleKinds {
+		if v.rule(rs, st, f, kind) != "" {
+			return true
 		}
 	}
+	return false
+}
 
-	// Game on!  Let's validate.
-	var bv bool
-	var err error
-	if exprTag != "" {
+// redact returns the value of a field as it may be shown, and whether
+// it was masked.
+func (v Validator) redact(w *walk, f reflect.StructField, val interface{}) (
+	interface{}, bool) {
+	if v.redactor != nil {
+		if rv, ok := v.redactor(f, val); ok {
+			return rv, true
+		}
+	}
+	if IsSensitive(f.Tag) || w.hidden > 0 {
+		return Redacted, true
+	}
+	return val, false
+}
 
-		// Support shortcuts for simple relational expressions, i.e.
-		// "<= 7" is a synonym for "<current field name> <= 7".
-		expr := exprTag
-		ts := strings.TrimSpace(expr)
-		switch ts[0] {
-		case '!':
-			// '!' could be a simple negation, so check "!=".
-			if len(ts) < 2 || ts[1] != '=' {
-				break
+// IsSensitive reports whether a field's tags mark it as sensitive.  It
+// is exported for tools, such as code generators, that must mask the
+// same values as the Validator.
+func IsSensitive(tag reflect.StructTag) bool {
+	for _, name := range []string{SensitiveTag, RedactTag} {
+		if val, ok := tag.Lookup(name); ok {
+			if b, err := strconv.ParseBool(val); err != nil || b {
+				return true
 			}
-			fallthrough
-		case '<', '>', '=':
-			// Must be start of right-hand side of expr or syntax error.
-			var buffer bytes.Buffer
-			buffer.WriteString(f.Name)
-			buffer.WriteString(" ")
-			buffer.WriteString(exprTag)
-			expr = buffer.String()
 		}
+	}
+	return false
+}
 
-		bv, err = v.eval.evalBoolExpr(f.Name, iface, expr)
-		if err != nil {
-			return err
+// ruleKinds are the kinds of rules that may be given by tags.
+var ruleKinds = []RuleKind{ExprRule, RegexpRule, RequiredRule,
+	TransitionRule, ImmutableRule}
+
+// A byKind holds a tag giving values for the rules of a field, such as
+// "warning,regexp=info", by rule kind, with the value for the rules of
+// any other kind under the empty kind.
+type byKind map[RuleKind]string
+
+// parseByKind parses a tag giving values by rule kind, allowing only
+// the kinds given.
+func parseByKind(tag string, kinds ...RuleKind) (byKind, error) {
+	bk := make(byKind)
+	if strings.TrimSpace(tag) == "" {
+		return bk, nil
+	}
+	for _, item := range strings.Split(tag, ",") {
+		kind, val, ok := strings.Cut(item, "=")
+		if !ok {
+			kind, val = "", kind
+		}
+		kind, val = strings.TrimSpace(kind), strings.TrimSpace(val)
+		if kind != "" && !containsKind(kinds, RuleKind(kind)) {
+			return nil, fmt.Errorf("unknown rule kind %q", kind)
 		}
+		if val == "" {
+			return nil, fmt.Errorf("missing value in %q", item)
+		}
+		bk[RuleKind(kind)] = val
+	}
+	return bk, nil
+}
 
-		if !bv || v.showSuccesses {
-			r := Result{
-				Name:  f.Name,
-				Value: iface,
-				Type:  f.Type,
-				Expr:  expr,
-				Valid: bv,
-			}
-			*res = append(*res, r)
+func containsKind(kinds []RuleKind, kind RuleKind) bool {
+	for _, k := range kinds {
+		if k == kind {
+			return true
 		}
 	}
+	return false
+}
 
-	if regexpTag != "" {
-		str := v.iToStr(iface)
-		bv, err = v.eval.evalRegexp(str, regexpTag)
-		if err != nil {
-			return err
+// of returns the value for the rules of the kind, if any.
+func (bk byKind) of(kind RuleKind) string {
+	if val, ok := bk[kind]; ok {
+		return val
+	}
+	return bk[""]
+}
+
+// severities holds the parsed severity tag of a field.
+type severities byKind
+
+// parseSeverity parses a severity tag.
+func parseSeverity(tag string) (severities, error) {
+	bk, err := parseByKind(tag, ruleKinds...)
+	if err != nil {
+		return nil, err
+	}
+	for _, level := range bk {
+		switch Severity(level) {
+		case SeverityError, SeverityWarning, SeverityInfo:
+		default:
+			return nil, fmt.Errorf("unknown severity %q", level)
 		}
-		if !bv || v.showSuccesses {
-			r := Result{
-				Name:  f.Name,
-				Value: iface,
-				Type:  f.Type,
-				Expr:  regexpTag,
-				Valid: bv,
-			}
-			*res = append(*res, r)
+	}
+	return severities(bk), nil
+}
+
+// of returns the severity of the rules of the kind.
+func (sev severities) of(kind RuleKind) Severity {
+	if level := byKind(sev).of(kind); level != "" {
+		return Severity(level)
+	}
+	return SeverityError
+}
+
+// SeverityOf returns the Severity of a field's rule of the given kind,
+// according to its severity tag, for tools that must agree with the
+// Validator.
+func SeverityOf(tag reflect.StructTag, kind RuleKind) (Severity, error) {
+	sev, err := parseSeverity(tag.Get(SeverityTag))
+	if err != nil {
+		return "", err
+	}
+	return sev.of(kind), nil
+}
+
+// parseCode parses a code tag.
+func parseCode(tag string) (byKind, error) {
+	return parseByKind(tag, append(ruleKinds, DecodeRule)...)
+}
+
+// ruleCode returns the Code of the rule of the kind for the field of the
+// struct type: that given by its code tag, or else the default.
+func ruleCode(codes byKind, st reflect.Type, f reflect.StructField,
+	kind RuleKind) string {
+	if code := codes.of(kind); code != "" {
+		return code
+	}
+	return DefaultCode(st.Name(), f.Name, kind)
+}
+
+// DefaultCode returns the Code of a rule with no code tag, made up of
+// the names of the struct type, the field and the rule kind, as in
+// "Order.Total.expr".  Empty names are left out, as the type name is for
+// an anonymous struct.
+func DefaultCode(typeName, fieldName string, kind RuleKind) string {
+	var parts []string
+	for _, part := range []string{typeName, fieldName, string(kind)} {
+		if part != "" {
+			parts = append(parts, part)
 		}
 	}
+	return strings.Join(parts, ".")
+}
+
+// CodeOf returns the Code of a field's rule of the given kind, according
+// to its code tag, or DefaultCode if it has none, for tools that must
+// agree with the Validator.
+func CodeOf(tag reflect.StructTag, typeName, fieldName string,
+	kind RuleKind) (string, error) {
+	codes, err := parseCode(tag.Get(CodeTag))
+	if err != nil {
+		return "", err
+	}
+	if code := codes.of(kind); code != "" {
+		return code, nil
+	}
+	return DefaultCode(typeName, fieldName, kind), nil
+}
 
-	lg.trace("result for '%s', '%s', value: '%v': %t\n",
-		regexpTag, f.Name, iface, bv)
-	return nil
+// ExpandShortcut supports shortcuts for simple relational expressions,
+// i.e. "<= 7" is a synonym for "<current field name> <= 7".  The
+// expression is returned unchanged if it is not a shortcut.  This is
+// exported for tools that need to see expressions as the Validator does.
+func ExpandShortcut(name, expr string) string {
+	if _, _, ok := splitShortcut(expr); !ok {
+		return expr
+	}
+
+	// Must be start of right-hand side of expr or syntax error.
+	var buffer bytes.Buffer
+	buffer.WriteString(name)
+	buffer.WriteString(" ")
+	buffer.WriteString(expr)
+	return buffer.String()
+}
+
+// splitShortcut breaks a shortcut relational expression such as
+// "<= 7" into its operator and right-hand side.
+func splitShortcut(expr string) (op, rhs string, ok bool) {
+	ts := strings.TrimSpace(expr)
+	if ts == "" {
+		return "", "", false
+	}
+	switch ts[0] {
+	case '!':
+		// '!' could be a simple negation, so check "!=".
+		if len(ts) < 2 || ts[1] != '=' {
+			return "", "", false
+		}
+	case '<', '>', '=':
+	default:
+		return "", "", false
+	}
+	end := strings.IndexFunc(ts, func(r rune) bool {
+		return !strings.ContainsRune("<>=!", r)
+	})
+	if end < 0 {
+		end = len(ts)
+	}
+	return ts[:end], strings.TrimSpace(ts[end:]), true
 }
 
 // For regexps, use a reasonable string value if we can
@@ -453,10 +1076,32 @@ func (v Validator) iToStr(i interface{}) string {
 	}
 }
 
+// pointerTo appends a reference token to a JSON pointer, escaping
+// it as per RFC 6901.
+func pointerTo(path, token string) string {
+	token = strings.ReplaceAll(token, "~", "~0")
+	token = strings.ReplaceAll(token, "/", "~1")
+	return path + "/" + token
+}
+
 func (res *Result) String() string {
+	// A missing value has no dynamic type, and a redacted one a
+	// misleading one, so fall back on the declared one.
 	tn := reflect.TypeOf(res.Value)
+	if tn == nil || res.Redacted {
+		tn = res.Type
+		for res.Redacted && tn != nil && tn.Kind() == reflect.Ptr {
+			tn = tn.Elem()
+		}
+	}
+	kind := reflect.Invalid
+	if tn != nil {
+		kind = tn.Kind()
+	}
 	var tstr string
-	switch tn.Kind() {
+	switch kind {
+	case reflect.Invalid:
+		tstr = "nil"
 	case reflect.Slice:
 		var name string
 		if tn.Elem().Kind() == reflect.Interface {
@@ -484,6 +1129,9 @@ func (res *Result) String() string {
 	valid := "ok"
 	if !res.Valid {
 		valid = "failed"
+		if !res.Fails() {
+			valid += " (" + string(res.Severity) + ")"
+		}
 	}
 	return fmt.Sprintf("'%s' (type: %v) item: '%+v', expr: '%s' : %s",
 		res.Name, tstr, res.Value, res.Expr, valid)
@@ -492,8 +1140,5 @@ func (res *Result) String() string {
 // PrintResults shows the lists of unsuccessful (and optioanlly successful)
 // validations.
 func PrintResults(w io.Writer, res []Result) {
-	fmt.Fprintln(w, "Results:")
-	for i := 0; i < len(res); i++ {
-		fmt.Fprintln(w, res[i].String())
-	}
+	TextReporter.Report(w, ResultSet{Results: res})
 }
@@ -1,10 +1,14 @@
 package tageval
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -418,6 +422,207 @@ func TestRegexpStringTypes(t *testing.T) {
 	correlate(t, res, expected)
 }
 
+func TestTagNames(t *testing.T) {
+	type Renamed struct {
+		A int    `tv-expr:"> 5" expr:"A == 0"`
+		B string `tv-re:"^hello$" regexp:"^x$"`
+		C string `regexp:"^x$"`
+	}
+
+	v, _ := NewValidator(ShowSuccesses(true), ExprTagName("tv-expr"),
+		RegexpTagName("tv-re"))
+	ok, res, err := v.Copy().Validate(Renamed{6, "hello", "y"})
+	if err != nil {
+		t.Fatalf("validation failed with error: %v", err)
+	}
+	if !ok {
+		t.Fatalf("unexpected failure result")
+	}
+	PrintResults(os.Stdout, res)
+	correlate(t, res, []checker{{"A", true}, {"B", true}})
+}
+
+func TestRedaction(t *testing.T) {
+	type Creds struct {
+		Token string `json:"token" expr:"Token.length > 8"`
+	}
+	type Account struct {
+		User     string  `json:"user" expr:"User.length > 8"`
+		Password string  `json:"password" sensitive:"true" expr:"Password.length >= 12"`
+		PIN      *int    `json:"pin" redact:"yes" expr:"PIN > 999"`
+		Card     string  `json:"card" regexp:"^[0-9]{16}$"`
+		Creds    Creds   `json:"creds" sensitive:"true"`
+		Limit    float64 `json:"limit" sensitive:"false" expr:"> 0"`
+	}
+
+	pin := 42
+	acct := Account{User: "bob", Password: "hunter2", PIN: &pin,
+		Card: "4111-1111", Creds: Creds{Token: "abc"}}
+	lastFour := func(f reflect.StructField, val interface{}) (interface{}, bool) {
+		if s, ok := val.(string); ok && f.Name == "Card" && len(s) > 4 {
+			return "..." + s[len(s)-4:], true
+		}
+		return nil, false
+	}
+
+	var logged bytes.Buffer
+	v, _ := NewValidator(WithRedactor(lastFour),
+		WithLogHandler(slog.NewTextHandler(&logged,
+			&slog.HandlerOptions{Level: LevelTrace})))
+	_, res, err := v.Validate(acct)
+	if err != nil {
+		t.Fatalf("validation failed with error: %v", err)
+	}
+	PrintResults(os.Stdout, res)
+	correlate(t, res, []checker{{"User", false}, {"Password", false},
+		{"PIN", false}, {"Card", false}, {"Token", false}, {"Limit", false}})
+
+	expected := []interface{}{"bob", Redacted, Redacted, "...1111", Redacted,
+		0.0}
+	for i, r := range res {
+		if r.Value != expected[i] || r.Redacted != (i > 0 && i < 5) {
+			t.Fatalf("result %d has value %v, expected %v", i, r.Value,
+				expected[i])
+		}
+	}
+	if s := res[2].String(); s != "'PIN' (type: int) item: '[REDACTED]', expr: 'PIN > 999' : failed" {
+		t.Fatalf("unexpected string form: %s", s)
+	}
+	if data, _ := json.Marshal(res[1]); !bytes.Contains(data,
+		[]byte(`"value":"[REDACTED]"`)) || !bytes.Contains(data,
+		[]byte(`"redacted":true`)) {
+		t.Fatalf("unexpected JSON form: %s", data)
+	}
+	if !strings.Contains(logged.String(), Redacted) {
+		t.Fatalf("log lacks masked values:\n%s", logged.String())
+	}
+	for _, secret := range []string{"hunter2", "42", "4111", "abc"} {
+		if strings.Contains(logged.String(), "value="+secret) {
+			t.Fatalf("log reveals %s:\n%s", secret, logged.String())
+		}
+	}
+
+	// Values that can't be decoded are masked too.
+	_, res, err = v.ValidateJSON([]byte(`{"user": "robert-the-bruce",
+		"password": 1234567, "creds": {"token": 5, "extra": "x"}}`),
+		&Account{})
+	if err != nil {
+		t.Fatalf("validation failed with error: %v", err)
+	}
+	PrintResults(os.Stdout, res)
+	for _, r := range res {
+		if r.Kind == DecodeRule && r.Value != Redacted {
+			t.Fatalf("decode result for %s reveals %v", r.Path, r.Value)
+		}
+	}
+}
+
+func TestSeverity(t *testing.T) {
+	type Product struct {
+		Name  string `json:"name" expr:"Name.length > 3" severity:"warning"`
+		SKU   string `json:"sku" regexp:"^[A-Z]{3}$" expr:"SKU.length < 5" severity:"regexp=info"`
+		Price int    `json:"price" required:"true" expr:"> 0" severity:"info,required=error"`
+	}
+
+	v, _ := NewValidator()
+	ok, res, err := v.Validate(Product{Name: "ab", SKU: "abcd", Price: -1})
+	if err != nil {
+		t.Fatalf("validation failed with error: %v", err)
+	}
+	PrintResults(os.Stdout, res)
+	if !ok {
+		t.Fatalf("unexpected failure for warnings: %v", res)
+	}
+	correlate(t, res, []checker{{"Name", false}, {"SKU", false},
+		{"Price", false}})
+	for i, sev := range []Severity{SeverityWarning, SeverityInfo,
+		SeverityInfo} {
+		if res[i].Severity != sev {
+			t.Fatalf("result %d has severity %s, expected %s", i,
+				res[i].Severity, sev)
+		}
+	}
+
+	// Only the missing price is an error.
+	ok, res, err = v.ValidateJSON([]byte(`{"name": "ab", "sku": "ab"}`),
+		&Product{})
+	if err != nil {
+		t.Fatalf("validation failed with error: %v", err)
+	}
+	PrintResults(os.Stdout, res)
+	var failed []string
+	for _, r := range res {
+		if r.Fails() {
+			failed = append(failed, r.Name+" "+string(r.Kind))
+		}
+	}
+	if ok || strings.Join(failed, ", ") != "Price required" {
+		t.Fatalf("unexpected failures %v", failed)
+	}
+
+	type Bad struct {
+		A int `expr:"> 0" severity:"expr=fatal"`
+	}
+	if _, _, err = v.Validate(Bad{}); err == nil {
+		t.Fatalf("did not get expected error for bad severity")
+	}
+}
+
+func TestCodes(t *testing.T) {
+	type Line struct {
+		Qty int `json:"qty" expr:"> 0"`
+	}
+	type Basket struct {
+		ID    string `json:"id" regexp:"^[0-9]+$" required:"true" code:"basket.id,required=basket.id.missing"`
+		Total int    `json:"total" expr:"> 0" code:"decode=basket.total.type"`
+		Lines []Line `json:"lines"`
+	}
+
+	v, _ := NewValidator()
+	_, res, err := v.Validate(Basket{ID: "x", Lines: []Line{{0}}})
+	if err != nil {
+		t.Fatalf("validation failed with error: %v", err)
+	}
+	PrintResults(os.Stdout, res)
+	var codes []string
+	for _, r := range res {
+		codes = append(codes, r.Code)
+	}
+	expected := "basket.id, Basket.Total.expr, Line.Qty.expr"
+	if strings.Join(codes, ", ") != expected {
+		t.Fatalf("expected codes %s, got %v", expected, codes)
+	}
+
+	_, res, err = v.ValidateJSON([]byte(`{"total": "5", "extra": 1,
+		"lines": [{"qty": true}]}`), &Basket{})
+	if err != nil {
+		t.Fatalf("validation failed with error: %v", err)
+	}
+	PrintResults(os.Stdout, res)
+	codes = nil
+	for _, r := range res {
+		codes = append(codes, r.Code)
+	}
+	expected = "Basket.extra.decode, Line.Qty.decode, basket.total.type, " +
+		"basket.id.missing, Basket.Total.expr, Line.Qty.expr"
+	if strings.Join(codes, ", ") != expected {
+		t.Fatalf("expected codes %s, got %v", expected, codes)
+	}
+
+	anon := struct {
+		A int `expr:"> 0"`
+	}{}
+	if _, res, _ = v.Validate(anon); len(res) != 1 || res[0].Code != "A.expr" {
+		t.Fatalf("unexpected results for anonymous struct: %v", res)
+	}
+	type Bad struct {
+		A int `expr:"> 0" code:"regexp="`
+	}
+	if _, _, err = v.Validate(Bad{}); err == nil {
+		t.Fatalf("did not get expected error for bad code")
+	}
+}
+
 func TestEvaluation(t *testing.T) {
 	v := newEvaluator()
 